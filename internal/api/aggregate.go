@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	groupKey    = "group"
+	bucketKey   = "bucket"
+	bucketByKey = "bucketby"
+	valueKey    = "value"
+	simtimeKey  = internal.FieldKey + fieldNamespace + "simtime" + fieldNamespace + internal.ValueKey
+	simtimeRaw  = internal.FieldKey + fieldNamespace + "simtime" + fieldNamespace + internal.NotJSON
+	nullJSON    = "null"
+	groupJoin   = "\x00"
+
+	// URL endpoints
+	aggregateURL = "/aggregate"
+)
+
+type (
+	aggregate struct {
+		Group  map[string]json.RawMessage `json:"group"`
+		Bucket *float64                   `json:"bucket,omitempty"`
+		Count  int                        `json:"count"`
+		Values int                        `json:"values,omitempty"`
+		Min    *float64                   `json:"min,omitempty"`
+		Max    *float64                   `json:"max,omitempty"`
+		Sum    *float64                   `json:"sum,omitempty"`
+		Avg    *float64                   `json:"avg,omitempty"`
+		key    string
+	}
+
+	// AggregateAdder handles counting (and numeric stats) grouped by fields and time buckets
+	AggregateAdder struct {
+		objectAdder
+		groups   []string
+		bucket   time.Duration
+		bucketBy string
+		value    string
+		tracked  map[string]*aggregate
+		invalid  []*problem
+	}
+)
+
+// NewAggregateAdder creates an aggregate adder from request parameters
+func NewAggregateAdder(req map[string][]string) *AggregateAdder {
+	a := &AggregateAdder{bucketBy: internal.TSKey}
+	for _, g := range req[groupKey] {
+		for _, p := range strings.Split(g, projectDelimiter) {
+			p = strings.TrimSpace(p)
+			if len(p) > 0 {
+				a.groups = append(a.groups, p)
+			}
+		}
+	}
+	if b, ok := req[bucketKey]; ok && len(b) > 0 {
+		d, err := parseSpan(b[0])
+		if err == nil && d < time.Millisecond {
+			err = fmt.Errorf("bucket must be at least 1ms")
+		}
+		if err != nil {
+			a.invalid = append(a.invalid, newProblem(bucketKey, b[0], err))
+		} else {
+			a.bucket = d
+		}
+	}
+	if b, ok := req[bucketByKey]; ok && len(b) > 0 {
+		switch b[0] {
+		case internal.TSKey, simtimeKey, simtimeRaw:
+			a.bucketBy = b[0]
+		default:
+			a.invalid = append(a.invalid, newProblem(bucketByKey, b[0], fmt.Errorf("unable to bucket by: %s", b[0])))
+		}
+	}
+	if v, ok := req[valueKey]; ok && len(v) > 0 {
+		a.value = strings.TrimSpace(v[0])
+	}
+	return a
+}
+
+func (a *AggregateAdder) params() []string {
+	return []string{groupKey, bucketKey, bucketByKey, valueKey}
+}
+
+func (a *AggregateAdder) problems() []*problem {
+	return a.invalid
+}
+
+// simtime gets the (typed, or raw if not declared) simtime of a record
+func simtime(j map[string]json.RawMessage) (float64, bool) {
+	for _, k := range []string{simtimeKey, simtimeRaw} {
+		if v, ok := fieldValue(j, k); ok {
+			return internal.JSONfloat64(v)
+		}
+	}
+	return 0, false
+}
+
+// bucketOf gets the start of the time bucket (ts in ms, simtime in seconds)
+func (a *AggregateAdder) bucketOf(j map[string]json.RawMessage) (float64, bool) {
+	if a.bucketBy == internal.TSKey {
+		v, ok := fieldValue(j, a.bucketBy)
+		if !ok {
+			return 0, false
+		}
+		i, ok := internal.JSONint64(v)
+		if !ok {
+			return 0, false
+		}
+		size := int64(a.bucket / time.Millisecond)
+		return float64(i - i%size), true
+	}
+	f, ok := simtime(j)
+	if !ok {
+		return 0, false
+	}
+	size := a.bucket.Seconds()
+	return math.Floor(f/size) * size, true
+}
+
+func (a *AggregateAdder) add(first bool, j map[string]json.RawMessage) {
+	if first {
+		a.tracked = make(map[string]*aggregate)
+	}
+	group := make(map[string]json.RawMessage)
+	keys := []string{}
+	for _, g := range a.groups {
+		v, ok := fieldValue(j, g)
+		if !ok {
+			v = json.RawMessage(nullJSON)
+		}
+		group[g] = v
+		keys = append(keys, string(v))
+	}
+	var bucket *float64
+	if a.bucket > 0 {
+		b, ok := a.bucketOf(j)
+		if !ok {
+			return
+		}
+		bucket = &b
+		keys = append(keys, fmt.Sprintf("%f", b))
+	}
+	k := strings.Join(keys, groupJoin)
+	cur, ok := a.tracked[k]
+	if !ok {
+		cur = &aggregate{Group: group, Bucket: bucket, key: k}
+		a.tracked[k] = cur
+	}
+	cur.Count++
+	if len(a.value) == 0 {
+		return
+	}
+	v, ok := fieldValue(j, a.value)
+	if !ok {
+		return
+	}
+	f, ok := internal.JSONfloat64(v)
+	if !ok {
+		return
+	}
+	cur.Values++
+	if cur.Sum == nil {
+		min, max, sum := f, f, 0.0
+		cur.Min, cur.Max, cur.Sum = &min, &max, &sum
+	}
+	*cur.Min = math.Min(*cur.Min, f)
+	*cur.Max = math.Max(*cur.Max, f)
+	*cur.Sum += f
+}
+
+func (a *AggregateAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	results := []*aggregate{}
+	for _, v := range a.tracked {
+		if v.Values > 0 {
+			avg := *v.Sum / float64(v.Values)
+			v.Avg = &avg
+		}
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		x := results[i]
+		y := results[j]
+		if x.Bucket != nil && y.Bucket != nil && *x.Bucket != *y.Bucket {
+			return *x.Bucket < *y.Bucket
+		}
+		return x.key < y.key
+	})
+	w.Write(ctx.byteHeader)
+	for i, r := range results {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			internal.Errored("unable to marshal aggregate", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
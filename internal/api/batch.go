@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	recordsQuery   = "records"
+	tagsQuery      = "tags"
+	aggregateQuery = "aggregate"
+	distinctQuery  = "distinct"
+	batchField     = "batch"
+
+	// URL endpoints
+	batchURL = "/batch"
+)
+
+type (
+	// BatchQuery is a named query within a batch
+	BatchQuery struct {
+		Name string `json:"name"`
+		// records (default), tags, aggregate, or distinct
+		Type  string       `json:"type"`
+		Query QueryRequest `json:"query"`
+		// any additional parameters (e.g. group for an aggregate)
+		Params map[string][]string `json:"params"`
+	}
+
+	cachedFile struct {
+		obj map[string]json.RawMessage
+		raw []byte
+	}
+
+	// fileCache holds loaded files so they are only read once
+	fileCache struct {
+		files   map[string]*cachedFile
+		Read    int `json:"read"`
+		Shared  int `json:"shared"`
+		Dropped int `json:"dropped"`
+	}
+)
+
+func newFileCache() *fileCache {
+	return &fileCache{files: make(map[string]*cachedFile)}
+}
+
+func (ctx *Context) load(path string, h *internal.Configuration) (map[string]json.RawMessage, []byte) {
+	if ctx.cache == nil {
+		return loadFile(path, h)
+	}
+	if c, ok := ctx.cache.files[path]; ok {
+		ctx.cache.Shared++
+		return c.obj, c.raw
+	}
+	obj, b := loadFile(path, h)
+	ctx.cache.Read++
+	ctx.cache.files[path] = &cachedFile{obj: obj, raw: b}
+	return obj, b
+}
+
+// keep drops any loaded files that are not in one of the day directories
+func (c *fileCache) keep(days []map[string]struct{}) {
+	for path := range c.files {
+		dir := filepath.Dir(path)
+		used := false
+		for _, d := range days {
+			if _, ok := d[dir]; ok {
+				used = true
+				break
+			}
+		}
+		if !used {
+			delete(c.files, path)
+			c.Dropped++
+		}
+	}
+}
+
+// DecodeBatch reads a JSON array of named queries
+func DecodeBatch(r io.Reader) ([]*BatchQuery, error) {
+	var batch []*BatchQuery
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&batch); err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{})
+	for _, b := range batch {
+		n := strings.TrimSpace(b.Name)
+		if len(n) == 0 {
+			return nil, fmt.Errorf("batch query has no name")
+		}
+		if _, ok := names[n]; ok {
+			return nil, fmt.Errorf("duplicate batch query name: %s", n)
+		}
+		names[n] = struct{}{}
+		b.Name = n
+	}
+	return batch, nil
+}
+
+func (b *BatchQuery) values() map[string][]string {
+	req := b.Query.Values()
+	for k, v := range b.Params {
+		req[k] = append(req[k], v...)
+	}
+	return req
+}
+
+// days gets the day directories (paths) the query reads
+func (b *BatchQuery) days(ctx *Context) map[string]struct{} {
+	days := make(map[string]struct{})
+	dirs, err := ctx.dayDirs(parseQuery(ctx, b.values(), 0))
+	if err != nil {
+		return days
+	}
+	for _, d := range dirs {
+		days[filepath.Join(ctx.Directory, d)] = struct{}{}
+	}
+	return days
+}
+
+// run runs a single query of the batch, the result is either the data or the errors
+func (b *BatchQuery) run(ctx *Context, h *internal.Configuration) []byte {
+	var buf bytes.Buffer
+	d := NewDataWriter(&buf, nil)
+	req := b.values()
+	switch b.Type {
+	case "", recordsQuery:
+	case tagsQuery:
+		d.limit = false
+		d.ObjectWriter(NewTagAdder(req))
+	case aggregateQuery:
+		d.limit = false
+		d.ObjectWriter(NewAggregateAdder(req))
+	case distinctQuery:
+		d.limit = false
+		d.ObjectWriter(NewDistinctAdder(req))
+	default:
+		d.errors = []*problem{{Param: "type", Value: b.Type, Message: "unknown batch query type"}}
+	}
+	if f, ok := req[formatKey]; ok && len(f) > 0 && f[0] != jsonFormat {
+		d.errors = append(d.errors, &problem{Param: formatKey, Value: f[0], Message: "batch queries only support json"})
+	}
+	if isFollow(req) {
+		d.errors = append(d.errors, &problem{Param: followKey, Message: "batch queries can not follow"})
+	}
+	if len(d.errors) == 0 && Handle(ctx, req, h, d) {
+		return buf.Bytes()
+	}
+	buf.Reset()
+	d.WriteErrors(&buf)
+	return buf.Bytes()
+}
+
+// HandleBatch runs all queries (sharing loaded files) writing a result per query name
+func HandleBatch(ctx *Context, batch []*BatchQuery, h *internal.Configuration, w io.Writer) {
+	shared := *ctx
+	shared.cache = newFileCache()
+	// meta is given once for the whole batch
+	shared.metaHeader = "{\"" + dataField + "\": ["
+	shared.byteHeader = []byte(shared.metaHeader)
+	// loaded files are dropped once no remaining query reads their day
+	days := []map[string]struct{}{}
+	for _, b := range batch {
+		days = append(days, b.days(&shared))
+	}
+	w.Write([]byte("{\"meta\": " + ctx.meta + ", \"" + dataField + "\": {"))
+	for i, b := range batch {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		name, err := json.Marshal(b.Name)
+		if err != nil {
+			internal.Errored("unable to marshal batch name", err)
+			name = []byte(nullJSON)
+		}
+		w.Write(name)
+		w.Write([]byte(": "))
+		w.Write(b.run(&shared, h))
+		if i < len(batch)-1 {
+			shared.cache.keep(days[i+1:])
+		}
+	}
+	stats, err := json.Marshal(shared.cache)
+	if err != nil {
+		internal.Errored("unable to marshal batch stats", err)
+		stats = []byte("{}")
+	}
+	w.Write([]byte("}, \"" + batchField + "\": " + string(stats) + "}"))
+}
+
+func batchRequest(ctx *Context, h *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	batch, err := DecodeBatch(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		d := &DataWriter{errors: []*problem{{Message: fmt.Sprintf("invalid batch: %v", err)}}}
+		writeErrors(w, d)
+		return
+	}
+	writeSuccess(w)
+	HandleBatch(ctx, batch, h, w)
+}
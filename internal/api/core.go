@@ -2,11 +2,13 @@ package api
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -25,23 +27,45 @@ const (
 	// IntConv for integer conversions
 	IntConv internal.TypeConv = 3
 	// Float64Conv for float64 conversions
-	Float64Conv     internal.TypeConv = 4
-	filterDelimiter                   = ":"
-	startStringOp                     = "ge"
-	endStringOp                       = "le"
-	eqStringOp                        = "eq"
-	limitIndicator                    = ", {\"limited\": \"true\"}"
-	spec                              = "0.1"
-	dataField                         = "data"
-	limitKey                          = "limit"
-	filterKey                         = "filter"
-	fieldNamespace                    = "."
-	defaultQuery                      = limitKey + "=0&" + filterKey + "=" + internal.FieldKey + fieldNamespace + internal.TagKey + fieldNamespace + internal.NotJSON + filterDelimiter + eqStringOp + filterDelimiter + "%s"
+	Float64Conv internal.TypeConv = 4
+	// BoolConv for bool conversions
+	BoolConv internal.TypeConv = 5
+	// TimeConv for (declared) time conversions
+	TimeConv         internal.TypeConv = 6
+	filterDelimiter                    = ":"
+	startStringOp                      = "ge"
+	endStringOp                        = "le"
+	eqStringOp                         = "eq"
+	spec                               = "0.1"
+	dataField                          = "data"
+	limitKey                           = "limit"
+	filterKey                          = "filter"
+	fieldNamespace                     = "."
+	wildcard                           = "*"
+	projectKey                         = "fields"
+	projectDelimiter                   = ","
+	defaultQuery                       = filterKey + "=" + internal.FieldKey + fieldNamespace + internal.TagKey + fieldNamespace + internal.NotJSON + filterDelimiter + eqStringOp + filterDelimiter + "%s"
+
+	daySuffix   = "d"
+	hoursPerDay = 24
+	defaultPoll = time.Second
 
 	// URL endpoints
 	tagURL = "/tags"
 )
 
+var (
+	// converters (by name) that can be configured
+	convNames = map[string]internal.TypeConv{
+		"int64":  Int64Conv,
+		"string": StrConv,
+		"int":    IntConv,
+		"float":  Float64Conv,
+		"bool":   BoolConv,
+		"time":   TimeConv,
+	}
+)
+
 type (
 	dataFilter struct {
 		field      string
@@ -50,6 +74,7 @@ type (
 		strVal     string
 		intVal     int
 		float64Val float64
+		boolVal    bool
 		fxn        internal.TypeConv
 	}
 
@@ -59,6 +84,7 @@ type (
 		Directory string
 		Convert   map[string]internal.TypeConv
 		// api output data
+		meta       string
 		metaFooter string
 		metaHeader string
 		byteHeader []byte
@@ -66,37 +92,64 @@ type (
 		// how we scan for data
 		ScanStart time.Duration
 		ScanEnd   time.Duration
+		// how often to check for new data
+		Poll time.Duration
+		// longest time to wait for new data (when following)
+		Follow time.Duration
+		// invalid request parameters are ignored (instead of failing the request)
+		Lenient bool
+		// time zone of the day directories (and any times given without one)
+		Zone *time.Location
+		// shared (loaded) files between requests
+		cache *fileCache
 	}
 
 	onHeaders func()
 
+	// onTrailers is given the paging state (cursor and if the page was limited)
+	onTrailers func(string, bool)
+
+	fieldCheck func([]byte) bool
+
+	// projection is a tree of the (dotted) paths to keep, a nil sub-projection keeps everything
+	projection map[string]projection
+
 	objectAdder interface {
 		add(bool, map[string]json.RawMessage)
-		done(*Context, io.Writer, bool)
+		done(*Context, io.Writer, *pageMeta)
 	}
 
-	tagMeta struct {
-		endTime      int64
-		endTimeStr   string
-		startTime    int64
-		startTimeStr string
+	// detailAdder is an object adder that can be limited to a single (identified) object
+	detailAdder interface {
+		objectAdder
+		found() bool
 	}
 
-	// TagAdder handles tagged results
-	TagAdder struct {
-		objectAdder
-		tracked map[string]*tagMeta
+	// dataPage is a (single) page of data results
+	dataPage struct {
+		Meta json.RawMessage   `json:"meta"`
+		Data []json.RawMessage `json:"data"`
+		Page pageMeta          `json:"page"`
 	}
 
 	// DataWriter handles writing data responses
 	DataWriter struct {
-		writer  io.Writer
-		write   bool
-		headers onHeaders
-		header  bool
-		objects objectAdder
-		object  bool
-		limit   bool
+		writer   io.Writer
+		write    bool
+		headers  onHeaders
+		trailers onTrailers
+		header   bool
+		objects  objectAdder
+		object   bool
+		limit    bool
+		format   string
+		columns  []string
+		csv      *csv.Writer
+		page     *pageMeta
+		errors   []*problem
+		warnings []*problem
+		// the request failed on the server (not because of the request)
+		failed bool
 	}
 )
 
@@ -110,6 +163,34 @@ func (f *dataFilter) check(d []byte) bool {
 		return internal.JSONstringConverter(f.strVal, d, f.op)
 	case Float64Conv:
 		return internal.JSONfloat64Converter(f.float64Val, d, f.op)
+	case BoolConv:
+		return internal.JSONboolConverter(f.boolVal, d, f.op)
+	case TimeConv:
+		return timeConverter(f.strVal, d, f.op)
+	}
+	return false
+}
+
+// timeConverter compares times (these are fixed width strings)
+func timeConverter(expect string, d []byte, op internal.OpType) bool {
+	s, ok := internal.JSONstring(d)
+	if !ok {
+		return false
+	}
+	c := strings.Compare(s, expect)
+	switch op {
+	case internal.Equals:
+		return c == 0
+	case internal.NEquals:
+		return c != 0
+	case internal.GreatThan:
+		return c > 0
+	case internal.LessThan:
+		return c < 0
+	case internal.GreatTE:
+		return c >= 0
+	case internal.LessTE:
+		return c <= 0
 	}
 	return false
 }
@@ -120,7 +201,91 @@ func DefaultConverters() map[string]internal.TypeConv {
 		internal.TSKey: Int64Conv,
 		internal.IDKey: StrConv,
 		fmt.Sprintf("%s.%s.%s", internal.FieldKey, internal.TagKey, internal.NotJSON): StrConv,
+		fmt.Sprintf("%s.%s", internal.FieldKey, internal.TagKey):                      StrConv,
+	}
+}
+
+// SchemaConverters are the converters for the declared (typed) message fields
+func SchemaConverters(h *internal.Configuration) map[string]internal.TypeConv {
+	converters := make(map[string]internal.TypeConv)
+	for name, as := range messages.Types(h) {
+		conv := StrConv
+		switch as {
+		case messages.FloatType:
+			conv = Float64Conv
+		case messages.IntType:
+			conv = Int64Conv
+		case messages.BoolType:
+			conv = BoolConv
+		case messages.TimeType:
+			conv = TimeConv
+		}
+		converters[fmt.Sprintf("%s.%s.%s", internal.FieldKey, name, internal.ValueKey)] = conv
+		converters[fmt.Sprintf("%s.%s", internal.FieldKey, name)] = conv
+	}
+	return converters
+}
+
+// ConfigConverters are the converters declared (by path) in the configuration
+func ConfigConverters(h *internal.Configuration) (map[string]internal.TypeConv, error) {
+	converters := make(map[string]internal.TypeConv)
+	for path, name := range h.API.Converters {
+		conv, ok := convNames[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown converter for %s: %s", path, name)
+		}
+		converters[strings.TrimSpace(path)] = conv
+	}
+	return converters, nil
+}
+
+// converter gets the type of a field path, array indexes also match a wildcard (e.g. fields.data.array.2 matches fields.data.array.*)
+func converter(mapping map[string]internal.TypeConv, field string) (internal.TypeConv, bool) {
+	if t, ok := indexedConverter(mapping, field); ok {
+		return t, true
+	}
+	parts := strings.Split(field, fieldNamespace)
+	if len(parts) < 2 || parts[0] != internal.FieldKey {
+		return 0, false
+	}
+	if len(parts) > 2 && isEntryKey(parts[2]) {
+		// raw values are always strings
+		if len(parts) == 3 && parts[2] == internal.NotJSON {
+			return StrConv, true
+		}
+		return 0, false
+	}
+	// flattened paths have the type of the entry path (e.g. fields.type is fields.type.raw)
+	entries := []string{internal.NotJSON}
+	if len(parts) > 2 {
+		entries = []string{internal.ObjJSON, internal.ArrayJSON}
+	}
+	for _, e := range entries {
+		path := append([]string{parts[0], parts[1], e}, parts[2:]...)
+		if t, ok := converter(mapping, strings.Join(path, fieldNamespace)); ok {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func indexedConverter(mapping map[string]internal.TypeConv, field string) (internal.TypeConv, bool) {
+	if t, ok := mapping[field]; ok {
+		return t, true
+	}
+	parts := strings.Split(field, fieldNamespace)
+	indexed := false
+	for i, p := range parts {
+		if _, err := strconv.Atoi(p); err == nil {
+			parts[i] = wildcard
+			indexed = true
+		}
+	}
+	if !indexed {
+		return 0, false
 	}
+	t, ok := mapping[strings.Join(parts, fieldNamespace)]
+	return t, ok
 }
 
 func stringToOp(op string) internal.OpType {
@@ -141,141 +306,227 @@ func stringToOp(op string) internal.OpType {
 	return internal.InvalidOp
 }
 
-func parseFilter(filter string, mapping map[string]internal.TypeConv) *dataFilter {
+func parseFilter(filter string, mapping map[string]internal.TypeConv) (*dataFilter, error) {
 	parts := strings.Split(filter, filterDelimiter)
 	if len(parts) < 3 {
-		internal.Info("filter missing components")
-		return nil
+		return nil, fmt.Errorf("filter missing components")
 	}
 	val := strings.Join(parts[2:], filterDelimiter)
 	f := &dataFilter{}
 	f.field = parts[0]
-	t, ok := mapping[f.field]
+	t, ok := converter(mapping, f.field)
 	if !ok {
-		internal.Info(fmt.Sprintf("filter field unknown: %s", f.field))
-		return nil
+		return nil, fmt.Errorf("filter field unknown: %s", f.field)
 	}
 	f.op = stringToOp(parts[1])
 	if f.op == internal.InvalidOp {
-		internal.Info("filter op invalid")
-		return nil
+		return nil, fmt.Errorf("filter op invalid: %s", parts[1])
 	}
 	f.fxn = t
 	switch t {
 	case IntConv:
 		i, e := strconv.Atoi(val)
 		if e != nil {
-			internal.Info("filter is not an int")
-			return nil
+			return nil, fmt.Errorf("filter is not an int: %s", val)
 		}
 		f.intVal = i
 	case Int64Conv:
 		i, e := strconv.ParseInt(val, 10, 64)
 		if e != nil {
-			internal.Info("filter is not an int64")
-			return nil
+			return nil, fmt.Errorf("filter is not an int64: %s", val)
 		}
 		f.int64Val = i
 	case Float64Conv:
 		i, e := strconv.ParseFloat(val, 64)
 		if e != nil {
-			internal.Info("filter is not a float64")
+			return nil, fmt.Errorf("filter is not a float64: %s", val)
 		}
 		f.float64Val = i
+	case BoolConv:
+		b, e := strconv.ParseBool(val)
+		if e != nil {
+			return nil, fmt.Errorf("filter is not a bool: %s", val)
+		}
+		if f.op != internal.Equals && f.op != internal.NEquals {
+			return nil, fmt.Errorf("filter bool op is invalid: %s", parts[1])
+		}
+		f.boolVal = b
+	case TimeConv:
+		t, e := parseTime(val, time.UTC, time.Now())
+		if e != nil {
+			return nil, fmt.Errorf("filter is not a time: %s", val)
+		}
+		f.strVal = t.UTC().Format(messages.TimeFormat)
 	case StrConv:
 		if f.op == internal.Equals || f.op == internal.NEquals {
 			f.strVal = val
 		} else {
-			internal.Info("filter string op is invalid")
-			return nil
+			return nil, fmt.Errorf("filter string op is invalid: %s", parts[1])
 		}
 	default:
-		internal.Info("unknown filter type")
-		return nil
+		return nil, fmt.Errorf("unknown filter type")
 	}
-	return f
+	return f, nil
 }
 
-func timeFilter(op, value string, mapping map[string]internal.TypeConv) *dataFilter {
-	return parseFilter(fmt.Sprintf("%s%s%s%s%s", internal.TSKey, filterDelimiter, op, filterDelimiter, value), mapping)
+func (p projection) add(parts []string) {
+	if len(parts) == 0 {
+		return
+	}
+	k := parts[0]
+	sub, ok := p[k]
+	if ok && sub == nil {
+		// already keeping all of it
+		return
+	}
+	if len(parts) == 1 {
+		p[k] = nil
+		return
+	}
+	if !ok {
+		sub = make(projection)
+		p[k] = sub
+	}
+	sub.add(parts[1:])
 }
 
-func getDate(in string, adding time.Duration) time.Time {
-	var t time.Time
-	if in == "" {
-		t = time.Now().Add(adding)
-	} else {
-		t, _ = time.Parse("2006-01-02", in)
+func (p projection) apply(obj map[string]json.RawMessage) map[string]json.RawMessage {
+	r := make(map[string]json.RawMessage)
+	for k, sub := range p {
+		v, ok := obj[k]
+		if !ok {
+			continue
+		}
+		if sub == nil {
+			r[k] = v
+			continue
+		}
+		var o map[string]json.RawMessage
+		if err := json.Unmarshal(v, &o); err != nil {
+			continue
+		}
+		b, err := json.Marshal(sub.apply(o))
+		if err != nil {
+			internal.Errored("unable to marshal projection", err)
+			continue
+		}
+		r[k] = b
 	}
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return r
 }
 
-func (t *TagAdder) add(first bool, j map[string]json.RawMessage) {
-	if first {
-		t.tracked = make(map[string]*tagMeta)
+func projectionPaths(values []string) []string {
+	paths := []string{}
+	for _, v := range values {
+		for _, path := range strings.Split(v, projectDelimiter) {
+			path = strings.TrimSpace(path)
+			if len(path) == 0 {
+				continue
+			}
+			paths = append(paths, path)
+		}
 	}
-	o, ok := getSubField(internal.FieldKey, j)
-	if !ok {
-		return
+	return paths
+}
+
+func parseProjection(values []string) projection {
+	p := make(projection)
+	for _, path := range projectionPaths(values) {
+		p.add(strings.Split(path, fieldNamespace))
 	}
-	o, ok = getSubField(internal.TagKey, o)
-	if !ok {
-		return
+	if len(p) == 0 {
+		return nil
 	}
-	v, ok := o[internal.NotJSON]
-	if !ok {
-		return
+	return p
+}
+
+func timeFilter(op, value string, mapping map[string]internal.TypeConv) (*dataFilter, error) {
+	return parseFilter(fmt.Sprintf("%s%s%s%s%s", internal.TSKey, filterDelimiter, op, filterDelimiter, value), mapping)
+}
+
+// poll is how often to check for new data (a default when not set)
+func (ctx *Context) poll() time.Duration {
+	if ctx.Poll <= 0 {
+		return defaultPoll
 	}
-	tsRaw, ok := j[internal.TSKey]
-	if !ok {
-		return
+	return ctx.Poll
+}
+
+func (ctx *Context) zone() *time.Location {
+	if ctx.Zone == nil {
+		return time.Local
 	}
-	dtRaw, ok := j[internal.DTKey]
-	if !ok {
-		return
+	return ctx.Zone
+}
+
+// dayOf gets the date of a day directory from its name (falling back to when it was modified)
+func dayOf(d os.FileInfo, loc *time.Location) time.Time {
+	if t, err := time.ParseInLocation(dayFormat, d.Name(), loc); err == nil {
+		return t
 	}
-	d, ok := internal.JSONstring(dtRaw)
-	if !ok {
-		return
+	return getDate(d.ModTime(), 0, loc)
+}
+
+// getDate gets the day (in the zone) of a time, defaulting to an offset from now
+func getDate(in time.Time, adding time.Duration, loc *time.Location) time.Time {
+	t := in
+	if t.IsZero() {
+		t = time.Now().Add(adding)
 	}
-	i, ok := internal.JSONint64(tsRaw)
-	if !ok {
-		return
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// dayRange gets the (directory) days covering the start and end dates, the dates are whole days in the query zone
+func dayRange(q *query, ctx *Context) (time.Time, time.Time) {
+	first := getDate(q.startDate, ctx.ScanStart, q.zone)
+	last := getDate(q.endDate, ctx.ScanEnd, q.zone).AddDate(0, 0, 1).Add(-time.Nanosecond)
+	return getDate(first, 0, ctx.zone()), getDate(last, 0, ctx.zone())
+}
+
+// dayDirs gets the (names of the) day directories a query reads
+func (ctx *Context) dayDirs(q *query) ([]string, error) {
+	dirs, err := ioutil.ReadDir(ctx.Directory)
+	if err != nil {
+		return nil, err
 	}
-	s := string(v)
-	cur, ok := t.tracked[s]
-	if ok {
-		if i >= cur.endTime {
-			cur.endTime = i
-			cur.endTimeStr = d
-		} else {
-			if i <= cur.startTime {
-				cur.startTime = i
-				cur.startTimeStr = d
-			} else {
-				return
+	if q.seek && len(dirs) > 0 {
+		dirs = dirs[len(dirs)-1:]
+	}
+	stime, etime := dayRange(q, ctx)
+	// without sorting the files are in directory order, so prior days can be ignored
+	byDay := q.order == nil
+	names := []string{}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dname := d.Name()
+		if !q.seek {
+			day := dayOf(d, ctx.zone())
+			if day.Before(stime) || day.After(etime) {
+				continue
 			}
 		}
-		t.tracked[s] = cur
-	} else {
-		t.tracked[s] = &tagMeta{endTime: i, endTimeStr: d, startTime: i, startTimeStr: d}
+		if q.cursor != nil && byDay && dname < q.cursor.Day {
+			continue
+		}
+		names = append(names, dname)
 	}
+	return names, nil
 }
 
-func (t *TagAdder) done(ctx *Context, w io.Writer, limit bool) {
-	w.Write(ctx.byteHeader)
-	first := true
-	for k, v := range t.tracked {
-		if !first {
-			w.Write([]byte(","))
+// parseSpan parses a duration, also supporting days (e.g. 1d)
+func parseSpan(value string) (time.Duration, error) {
+	v := strings.TrimSpace(value)
+	if strings.HasSuffix(v, daySuffix) {
+		i, err := strconv.Atoi(strings.TrimSuffix(v, daySuffix))
+		if err != nil {
+			return 0, err
 		}
-		w.Write([]byte(fmt.Sprintf("{%s: [%d, \"%s\", %d, \"%s\"]}", k, v.startTime, v.startTimeStr, v.endTime, v.endTimeStr)))
-		first = false
+		return time.Duration(i) * hoursPerDay * time.Hour, nil
 	}
-	if limit {
-		w.Write([]byte(limitIndicator))
-	}
-	w.Write(ctx.byteFooter)
+	return time.ParseDuration(v)
 }
 
 // NewDataWriter inits a new data writer for use
@@ -307,176 +558,289 @@ func (d *DataWriter) addString(s string) {
 
 // Handle is how we handle data requests
 func Handle(ctx *Context, req map[string][]string, h *internal.Configuration, writer *DataWriter) bool {
-	dataFilters := []*dataFilter{}
-	limited := 0
+	started := time.Now()
+	limit := 0
 	if writer.limit {
-		limited = ctx.Limit
-	}
-	skip := 0
-	startDate := ""
-	endDate := ""
-	fileRead := ""
-	seek := false
-	for k, p := range req {
-		if len(p) == 0 {
-			continue
+		limit = ctx.Limit
+	}
+	q := parseQuery(ctx, req, limit)
+	known := queryKeys
+	if writer.object {
+		if p, ok := writer.objects.(paramAdder); ok {
+			known = append(append([]string{}, queryKeys...), p.params()...)
+			q.errors = append(q.errors, p.problems()...)
+		}
+		// objects are always written as json
+		if len(q.format) > 0 && q.format != jsonFormat {
+			q.errors = append(q.errors, &problem{Param: formatKey, Value: q.format, Message: "only json is supported"})
+			q.format = ""
 		}
-		switch k {
-		case filterKey:
-			for _, val := range p {
-				f := parseFilter(val, ctx.Convert)
-				if f != nil {
-					dataFilters = append(dataFilters, f)
-				}
-			}
-
-		case "start":
-			fallthrough
-		case "end":
-			mode := endStringOp
-			if k == "start" {
-				mode = startStringOp
-			}
-			f := timeFilter(mode, p[0], ctx.Convert)
-			if f != nil {
-				dataFilters = append(dataFilters, f)
-			}
-		case limitKey:
-			i, err := strconv.Atoi(p[0])
-			if err == nil {
-				limited = i
-			}
-		case "skip":
-			i, err := strconv.Atoi(p[0])
-			if err == nil && i > 0 {
-				skip = i
-			}
-		case "files":
-			fileRead = strings.TrimSpace(p[0])
-		case "startdate":
-			startDate = strings.TrimSpace(p[0])
-		case "enddate":
-			endDate = strings.TrimSpace(p[0])
-		case "seek":
-			seek = true
-		}
-	}
-	stime := getDate(startDate, ctx.ScanStart)
-	etime := getDate(endDate, ctx.ScanEnd)
-	dirs, e := ioutil.ReadDir(ctx.Directory)
-	if seek && len(dirs) > 0 {
-		last := dirs[len(dirs)-1]
-		dirs = []os.FileInfo{last}
 	}
+	q.warnings = append(q.warnings, unknownParams(req, known)...)
+	if len(q.errors) > 0 {
+		logProblems(q.errors)
+		if q.strict {
+			writer.errors = q.errors
+			writer.warnings = q.warnings
+			return false
+		}
+		q.warnings = append(q.warnings, q.errors...)
+	}
+	if len(q.columns) > 0 {
+		writer.columns = q.columns
+	}
+	if len(q.format) > 0 {
+		writer.format = q.format
+	}
+	limited := q.limit
+	skip := q.skip
+	order := q.order
+	cursor := q.cursor
+	byDay := order == nil
+	dirs, e := ctx.dayDirs(q)
 	if e != nil {
 		internal.Errored("unable to read dir", e)
+		writer.errors = []*problem{{Message: "unable to read data"}}
+		writer.failed = true
 		return false
 	}
-	filterFiles := len(fileRead) > 0
+	filterFiles := len(q.files) > 0
 	files := []string{}
-	for _, d := range dirs {
-		dname := d.Name()
-		if d.IsDir() {
-			mtime := d.ModTime()
-			if !seek {
-				if mtime.Before(stime) || mtime.After(etime) {
+	for _, dname := range dirs {
+		p := filepath.Join(ctx.Directory, dname)
+		f, e := ioutil.ReadDir(p)
+		if e != nil {
+			internal.Info(fmt.Sprintf("unable to read subdir: %s", dname))
+			internal.Errored("reading subdir failed", e)
+			continue
+		}
+		for _, file := range f {
+			name := file.Name()
+			if filterFiles {
+				if !strings.HasPrefix(name, q.files) {
 					continue
 				}
 			}
-			p := filepath.Join(ctx.Directory, dname)
-			f, e := ioutil.ReadDir(p)
-			if e != nil {
-				internal.Info(fmt.Sprintf("unable to read subdir: %s", dname))
-				internal.Errored("reading subdir failed", e)
-				continue
-			}
-			for _, file := range f {
-				name := file.Name()
-				if filterFiles {
-					if !strings.HasPrefix(name, fileRead) {
-						continue
-					}
-				}
-				files = append(files, filepath.Join(p, name))
-			}
+			files = append(files, filepath.Join(p, name))
 		}
 	}
 
+	if order != nil && order.byName() {
+		files = order.files(files)
+	}
+	page := &pageMeta{Warnings: q.warnings}
 	count := 0
 	has := false
 	writer.setHeaders()
-	writer.addString(ctx.metaHeader)
+	writer.begin(ctx)
 	hasMore := false
-	for _, p := range files {
-		if limited > 0 && count > limited {
-			if has {
-				hasMore = true
-			}
-			break
-		}
-		obj, b := loadFile(p, h)
-		if len(dataFilters) > 0 {
-			valid := false
-			for _, d := range dataFilters {
-				filterObj := obj
-				parts := strings.Split(d.field, fieldNamespace)
-				fieldLen := len(parts) - 1
-				for i, p := range parts {
-					v, ok := filterObj[p]
-					if !ok {
-						break
-
-					}
-					if i == fieldLen {
-						valid = d.check(v)
-						break
-					} else {
-						var sub map[string]json.RawMessage
-						if err := json.Unmarshal(v, &sub); err != nil {
-							internal.Info(fmt.Sprintf("unable to unmarshal obj: %s (%s)", p, d.field))
-							internal.Errored("unmarshal error", err)
-							break
-						}
-						filterObj = sub
-					}
-				}
-				if !valid {
-					break
-				}
-			}
-			if !valid {
-				continue
-			}
+	lastPath := ""
+	lastPos := 0
+	emit := func(obj map[string]json.RawMessage, b []byte, path string, pos int) bool {
+		if limited > 0 && count >= limited {
+			hasMore = has
+			return false
 		}
+		page.Matched++
 		if skip > 0 {
 			skip += -1
-			continue
+			return true
 		}
-		if has {
-			writer.addString(",")
+		if writer.format != csvFormat {
+			r, ok := q.output(obj, b)
+			if !ok {
+				return true
+			}
+			b = r
 		}
-		writer.add(b)
+		writer.record(!has, obj, b)
 		writer.addObject(!has, obj)
 		has = true
 		count++
+		lastPath = path
+		lastPos = pos
+		return true
+	}
+	buffer := order != nil && !order.byName()
+	start := 0
+	if cursor != nil && !buffer {
+		start = cursor.resume(files)
+	}
+	var records []*record
+	for idx := start; idx < len(files); idx++ {
+		p := files[idx]
+		obj, b := ctx.load(p, h)
+		page.Scanned++
+		if obj == nil {
+			page.Failed++
+			continue
+		}
+		if !q.matches(obj) {
+			continue
+		}
+		if buffer {
+			records = append(records, order.newRecord(p, obj, b))
+			continue
+		}
+		pos := idx
+		if byDay {
+			pos = dayPosition(files, idx)
+		}
+		if !emit(obj, b, p, pos) {
+			break
+		}
+	}
+	if buffer {
+		order.sort(records)
+		if cursor != nil {
+			paths := []string{}
+			for _, r := range records {
+				paths = append(paths, r.path)
+			}
+			start = cursor.resume(paths)
+		}
+		for idx := start; idx < len(records); idx++ {
+			r := records[idx]
+			if !emit(r.obj, r.raw, r.path, idx) {
+				break
+			}
+		}
 	}
+	page.Count = count
+	page.Limited = hasMore
 	if hasMore {
-		writer.addString(limitIndicator)
+		page.Cursor = newCursor(lastPath, lastPos, q.sortBy).encode()
+	} else {
+		if q.follow {
+			// following always continues from what was last seen
+			page.Cursor = q.token
+			if buffer {
+				if len(lastPath) > 0 {
+					page.Cursor = newCursor(lastPath, lastPos, q.sortBy).encode()
+				}
+			} else {
+				last := len(files) - 1
+				if last >= start {
+					pos := last
+					if byDay {
+						pos = dayPosition(files, last)
+					}
+					page.Cursor = newCursor(files[last], pos, q.sortBy).encode()
+				}
+			}
+		}
 	}
-	writer.addString(ctx.metaFooter)
-	writer.closeObjects(ctx, hasMore)
+	page.Elapsed = int64(time.Since(started) / time.Millisecond)
+	writer.end(page)
+	writer.closeObjects(ctx, page)
+	writer.page = page
 	return true
 }
 
-func writeSuccess(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
+func isJSONArray(v json.RawMessage) bool {
+	t := bytes.TrimSpace(v)
+	return len(t) > 0 && t[0] == '['
+}
+
+// walk the remaining path parts from 'v', arrays support either an index or a wildcard (any element)
+func matchPath(v json.RawMessage, parts []string, field string, check fieldCheck) bool {
+	if len(parts) == 0 {
+		if check(v) {
+			return true
+		}
+		// flattened paths end at an entry (e.g. fields.simtime instead of fields.simtime.value)
+		if p, ok := plainEntry(v); ok {
+			return check(p)
+		}
+		return false
+	}
+	p := parts[0]
+	next := parts[1:]
+	// flattened paths continue past an entry (e.g. fields.data.weapon instead of fields.data.object.weapon)
+	if !isEntryKey(p) {
+		if e, ok := plainEntry(v); ok {
+			return matchPath(e, parts, field, check)
+		}
+	}
+	if isJSONArray(v) {
+		var arr []json.RawMessage
+		if err := json.Unmarshal(v, &arr); err != nil {
+			internal.Info(fmt.Sprintf("unable to unmarshal array: %s (%s)", p, field))
+			internal.Errored("unmarshal error", err)
+			return false
+		}
+		if p == wildcard {
+			for _, a := range arr {
+				if matchPath(a, next, field, check) {
+					return true
+				}
+			}
+			return false
+		}
+		idx, err := strconv.Atoi(p)
+		if err != nil || idx < 0 || idx >= len(arr) {
+			return false
+		}
+		return matchPath(arr[idx], next, field, check)
+	}
+	var sub map[string]json.RawMessage
+	if err := json.Unmarshal(v, &sub); err != nil {
+		internal.Info(fmt.Sprintf("unable to unmarshal obj: %s (%s)", p, field))
+		internal.Errored("unmarshal error", err)
+		return false
+	}
+	n, ok := sub[p]
+	if !ok {
+		return false
+	}
+	return matchPath(n, next, field, check)
+}
+
+func walkField(obj map[string]json.RawMessage, field string, check fieldCheck) bool {
+	parts := strings.Split(field, fieldNamespace)
+	v, ok := obj[parts[0]]
+	if !ok {
+		return false
+	}
+	return matchPath(v, parts[1:], field, check)
+}
+
+func matchField(obj map[string]json.RawMessage, f *dataFilter) bool {
+	return walkField(obj, f.field, f.check)
+}
+
+// fieldValue gets the (first) value at the given path
+func fieldValue(obj map[string]json.RawMessage, field string) (json.RawMessage, bool) {
+	var val json.RawMessage
+	ok := walkField(obj, field, func(v []byte) bool {
+		val = v
+		return true
+	})
+	return val, ok
+}
+
+func writeContent(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", content)
 	w.WriteHeader(http.StatusOK)
 }
 
+func writeSuccess(w http.ResponseWriter) {
+	writeContent(w, jsonContent)
+}
+
 func newWebDataWriter(w http.ResponseWriter) *DataWriter {
-	return NewDataWriter(w, func() {
-		writeSuccess(w)
+	var d *DataWriter
+	d = NewDataWriter(w, func() {
+		if d.trailed() {
+			w.Header().Set("Trailer", cursorTrailer+", "+limitedTrailer)
+		}
+		writeContent(w, d.contentType())
+	})
+	d.Trailers(func(cursor string, limited bool) {
+		w.Header().Set(cursorTrailer, cursor)
+		w.Header().Set(limitedTrailer, strconv.FormatBool(limited))
 	})
+	return d
 }
 
 func (d *DataWriter) addObject(first bool, o map[string]json.RawMessage) {
@@ -485,17 +849,60 @@ func (d *DataWriter) addObject(first bool, o map[string]json.RawMessage) {
 	}
 }
 
-func (d *DataWriter) closeObjects(ctx *Context, limited bool) {
+func (d *DataWriter) closeObjects(ctx *Context, page *pageMeta) {
 	if d.object {
-		d.objects.done(ctx, d.writer, limited)
+		d.objects.done(ctx, d.writer, page)
 	}
 }
 
 func webRequest(ctx *Context, h *internal.Configuration, w http.ResponseWriter, r *http.Request, d *DataWriter) {
-	success := Handle(ctx, r.URL.Query(), h, d)
+	webQuery(ctx, h, w, r, d, r.URL.Query())
+}
+
+func webQuery(ctx *Context, h *internal.Configuration, w http.ResponseWriter, r *http.Request, d *DataWriter, req map[string][]string) {
+	if format := acceptFormat(r.Header.Get("Accept")); len(format) > 0 && !d.object {
+		d.format = format
+	}
+	success := false
+	if !d.object && isFollow(req) {
+		success = HandleFollow(ctx, h, req, d, r.Context().Done())
+	} else {
+		success = Handle(ctx, req, h, d)
+	}
 	if !success {
-		w.WriteHeader(http.StatusBadRequest)
+		writeErrors(w, d)
+	}
+}
+
+// pathID gets the identifier following an endpoint (e.g. /sessions/{id})
+func pathID(r *http.Request, endpoint string) string {
+	return strings.Trim(strings.TrimPrefix(r.URL.Path, endpoint), "/")
+}
+
+// detailRequest lists all objects or (given an identifier) the details of one, which may not be found
+func detailRequest(ctx *Context, h *internal.Configuration, w http.ResponseWriter, r *http.Request, adder detailAdder, id string) {
+	if len(id) == 0 {
+		d := newWebDataWriter(w)
+		d.limit = false
+		d.ObjectWriter(adder)
+		webRequest(ctx, h, w, r, d)
+		return
+	}
+	var b bytes.Buffer
+	d := NewDataWriter(&b, nil)
+	d.limit = false
+	d.ObjectWriter(adder)
+	if !Handle(ctx, r.URL.Query(), h, d) {
+		writeErrors(w, d)
+		return
+	}
+	if !adder.found() {
+		d.errors = []*problem{{Param: internal.IDKey, Value: id, Message: fmt.Sprintf("not found: %s", id)}}
+		writeStatus(w, d, http.StatusNotFound)
+		return
 	}
+	writeSuccess(w)
+	w.Write(b.Bytes())
 }
 
 // ObjectWriter appends an object to the writer/output data
@@ -505,25 +912,14 @@ func (d *DataWriter) ObjectWriter(adder objectAdder) {
 	d.objects = adder
 }
 
-func getSubField(key string, j map[string]json.RawMessage) (map[string]json.RawMessage, bool) {
-	v, ok := j[key]
-	if !ok {
-		return nil, false
-	}
-	var sub map[string]json.RawMessage
-	if err := json.Unmarshal(v, &sub); err != nil {
-		return nil, false
-	}
-	return sub, true
-}
-
 func apiMeta(ctx *Context, started string) []byte {
 	return []byte(fmt.Sprintf("%s {\"started\": \"%s\"} %s", ctx.metaHeader, started, ctx.metaFooter))
 }
 
 // SetMeta indicates metadata for the context run
 func (ctx *Context) SetMeta(version, host string) {
-	ctx.metaHeader = "{\"meta\": {\"spec\": \"" + spec + "\", \"api\": \"" + version + "\", \"server\": \"" + host + "\"}, \"" + dataField + "\": ["
+	ctx.meta = "{\"spec\": \"" + spec + "\", \"api\": \"" + version + "\", \"server\": \"" + host + "\"}"
+	ctx.metaHeader = "{\"meta\": " + ctx.meta + ", \"" + dataField + "\": ["
 	ctx.metaFooter = "]}"
 	ctx.byteHeader = []byte(ctx.metaHeader)
 	ctx.byteFooter = []byte(ctx.metaFooter)
@@ -548,7 +944,46 @@ func listen(ctx *Context, vers string, conf *internal.Configuration) {
 	http.HandleFunc(tagURL, func(w http.ResponseWriter, r *http.Request) {
 		obj := newWebDataWriter(w)
 		obj.limit = false
-		obj.ObjectWriter(&TagAdder{})
+		obj.ObjectWriter(NewTagAdder(r.URL.Query()))
+		webRequest(ctx, conf, w, r, obj)
+	})
+	http.HandleFunc(queryURL, func(w http.ResponseWriter, r *http.Request) {
+		postRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(batchURL, func(w http.ResponseWriter, r *http.Request) {
+		batchRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(streamURL, func(w http.ResponseWriter, r *http.Request) {
+		streamRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(sessionsURL, func(w http.ResponseWriter, r *http.Request) {
+		sessionRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(sessionsURL+"/", func(w http.ResponseWriter, r *http.Request) {
+		sessionRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(playersURL, func(w http.ResponseWriter, r *http.Request) {
+		playerRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(playersURL+"/", func(w http.ResponseWriter, r *http.Request) {
+		playerRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(timelineURL+"/", func(w http.ResponseWriter, r *http.Request) {
+		timelineRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(recordsURL+"/", func(w http.ResponseWriter, r *http.Request) {
+		recordRequest(ctx, conf, w, r)
+	})
+	http.HandleFunc(distinctURL, func(w http.ResponseWriter, r *http.Request) {
+		obj := newWebDataWriter(w)
+		obj.limit = false
+		obj.ObjectWriter(NewDistinctAdder(r.URL.Query()))
+		webRequest(ctx, conf, w, r, obj)
+	})
+	http.HandleFunc(aggregateURL, func(w http.ResponseWriter, r *http.Request) {
+		obj := newWebDataWriter(w)
+		obj.limit = false
+		obj.ObjectWriter(NewAggregateAdder(r.URL.Query()))
 		webRequest(ctx, conf, w, r, obj)
 	})
 	if err := http.ListenAndServe(bind, nil); err != nil {
@@ -563,7 +998,52 @@ func pullData(url string) ([]byte, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.Status, b)
+	}
+	return b, nil
+}
+
+// responseError describes a failed response (using the reported problems when given)
+func responseError(status string, b []byte) error {
+	errs := &problemResponse{}
+	if err := json.Unmarshal(b, errs); err != nil || len(errs.Errors) == 0 {
+		return fmt.Errorf("request failed: %s", status)
+	}
+	msgs := []string{}
+	for _, p := range errs.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s (%s) %s", p.Param, p.Value, p.Message))
+	}
+	return fmt.Errorf("request failed: %s: %s", status, strings.Join(msgs, ", "))
+}
+
+// pullPages follows the page cursors until all data has been retrieved
+func pullPages(target string) ([]byte, error) {
+	result := &dataPage{Data: []json.RawMessage{}}
+	next := target
+	for {
+		b, err := pullData(next)
+		if err != nil {
+			return nil, err
+		}
+		page := &dataPage{}
+		if err := json.Unmarshal(b, page); err != nil {
+			return nil, err
+		}
+		if result.Meta == nil {
+			result.Meta = page.Meta
+		}
+		result.Data = append(result.Data, page.Data...)
+		if len(page.Page.Cursor) == 0 {
+			break
+		}
+		next = fmt.Sprintf("%s&%s=%s", target, cursorKey, url.QueryEscape(page.Page.Cursor))
+	}
+	return json.Marshal(result)
 }
 
 func prettifyToFile(target string, data []byte) error {
@@ -599,7 +1079,7 @@ func extract(conf *internal.Configuration, args []string) error {
 			return err
 		}
 	}
-	var tagData []map[string]json.RawMessage
+	var tagData []*tagInfo
 	if err := json.Unmarshal(data, &tagData); err != nil {
 		return err
 	}
@@ -609,36 +1089,64 @@ func extract(conf *internal.Configuration, args []string) error {
 	}
 	internal.Info("pulling data...")
 	for _, tag := range tagData {
-		internal.Info(fmt.Sprintf("downloading: %s", tag))
-		for idx := range tag {
-			dumpFile := filepath.Join(conf.API.Extract, idx+".json")
-			if internal.PathExists(dumpFile) {
-				internal.Info("already downloaded...")
-				continue
-			}
-			dump, err := pullData(fmt.Sprintf("%s/?%s", bound, fmt.Sprintf(query, idx)))
-			if err != nil {
-				return err
-			}
-			if err := prettifyToFile(dumpFile, dump); err != nil {
-				return err
-			}
+		internal.Info(fmt.Sprintf("downloading: %s", tag.Tag))
+		dumpFile := filepath.Join(conf.API.Extract, tag.Tag+".json")
+		if internal.PathExists(dumpFile) {
+			internal.Info("already downloaded...")
+			continue
+		}
+		dump, err := pullPages(fmt.Sprintf("%s/?%s", bound, fmt.Sprintf(query, url.QueryEscape(tag.Tag))))
+		if err != nil {
+			return err
+		}
+		if err := prettifyToFile(dumpFile, dump); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// Run runs the API listener
-func Run(vers string) {
-	conf, args := internal.Startup(vers)
-	dir := conf.Global.Output
-	limit := conf.API.Limit
+// NewContext creates a context from the configuration
+func NewContext(conf *internal.Configuration) (*Context, error) {
 	ctx := &Context{}
-	ctx.Limit = limit
-	ctx.Directory = dir
+	ctx.Limit = conf.API.Limit
+	ctx.Directory = conf.Global.Output
 	ctx.Convert = DefaultConverters()
 	ctx.ScanStart = time.Duration(conf.API.StartScan) * 24 * time.Hour
 	ctx.ScanEnd = time.Duration(conf.API.EndScan) * 24 * time.Hour
+	ctx.Poll = defaultPoll
+	if conf.API.Poll > 0 {
+		ctx.Poll = time.Duration(conf.API.Poll) * time.Millisecond
+	}
+	ctx.Lenient = conf.API.Lenient
+	ctx.Zone = conf.DayZone()
+	if err := messages.LoadSchemas(conf.API.Schemas); err != nil {
+		return nil, err
+	}
+	for k, v := range SchemaConverters(conf) {
+		ctx.Convert[k] = v
+	}
+	converters, err := ConfigConverters(conf)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range converters {
+		ctx.Convert[k] = v
+	}
+	ctx.Follow = defaultFollow
+	if conf.API.Follow > 0 {
+		ctx.Follow = time.Duration(conf.API.Follow) * time.Second
+	}
+	return ctx, nil
+}
+
+// Run runs the API listener
+func Run(vers string) {
+	conf, args := internal.Startup(vers)
+	ctx, err := NewContext(conf)
+	if err != nil {
+		internal.Fatal("unable to setup api", err)
+	}
 	if conf.API.Service {
 		internal.Info("running as service")
 		listen(ctx, vers, conf)
@@ -679,7 +1187,8 @@ func loadFile(path string, h *internal.Configuration) (map[string]json.RawMessag
 		if ok {
 			var fields map[string]*internal.Entry
 			if err := json.Unmarshal(v, &fields); err == nil {
-				rewrite := messages.HandleEntries(fields, h)
+				vers, _ := internal.JSONstring(obj[internal.VersKey])
+				rewrite := messages.HandleEntries(fields, vers, h)
 				r, err := json.Marshal(rewrite)
 				if err == nil {
 					obj[internal.FieldKey] = r
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	cursorKey = "cursor"
+	pageField = "page"
+)
+
+type (
+	// pageCursor is where a page ended, day + file are authoritative and position is a hint
+	pageCursor struct {
+		Day  string `json:"d"`
+		File string `json:"f"`
+		Pos  int    `json:"p"`
+		Sort string `json:"s,omitempty"`
+	}
+
+	// pageMeta is the trailing (after data) metadata of a response
+	pageMeta struct {
+		// records returned
+		Count int `json:"count"`
+		// records that matched the filters (including skipped)
+		Matched int `json:"matched"`
+		// files read
+		Scanned int `json:"scanned"`
+		// files unable to be read/parsed
+		Failed int `json:"failed"`
+		// time to handle the request (ms)
+		Elapsed int64 `json:"elapsed"`
+		// limit reached (more data available)
+		Limited bool   `json:"limited"`
+		Cursor  string `json:"cursor"`
+		// problems that did not stop the request
+		Warnings []*problem `json:"warnings,omitempty"`
+	}
+)
+
+func newCursor(path string, pos int, sort string) *pageCursor {
+	return &pageCursor{Day: filepath.Base(filepath.Dir(path)), File: filepath.Base(path), Pos: pos, Sort: sort}
+}
+
+func parseCursor(token, sort string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+	c := &pageCursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor data: %v", err)
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor was created for a different sort")
+	}
+	return c, nil
+}
+
+func (c *pageCursor) encode() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		internal.Errored("unable to encode cursor", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (c *pageCursor) matches(path string) bool {
+	return filepath.Base(path) == c.File && filepath.Base(filepath.Dir(path)) == c.Day
+}
+
+// resume gets the index to continue from (the entry after the cursor)
+func (c *pageCursor) resume(paths []string) int {
+	if c.Pos >= 0 && c.Pos < len(paths) && c.matches(paths[c.Pos]) {
+		return c.Pos + 1
+	}
+	for i, p := range paths {
+		if c.matches(p) {
+			return i + 1
+		}
+	}
+	internal.Info("cursor record not found, resuming by position")
+	if c.Pos+1 > len(paths) {
+		return len(paths)
+	}
+	if c.Pos < 0 {
+		return 0
+	}
+	return c.Pos + 1
+}
+
+// dayPosition is the position of the file relative to the start of its day (directory)
+func dayPosition(paths []string, idx int) int {
+	dir := filepath.Dir(paths[idx])
+	start := idx
+	for start > 0 && filepath.Dir(paths[start-1]) == dir {
+		start--
+	}
+	return idx - start
+}
+
+func (p *pageMeta) footer() string {
+	b, err := json.Marshal(p)
+	if err != nil {
+		internal.Errored("unable to marshal page metadata", err)
+		b = []byte("{}")
+	}
+	return "], \"" + pageField + "\": " + string(b) + "}"
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	pathKey = "path"
+	tagPath = internal.FieldKey + fieldNamespace + internal.TagKey + fieldNamespace + internal.NotJSON
+
+	// URL endpoints
+	distinctURL = "/distinct"
+)
+
+type (
+	valueMeta struct {
+		Value    json.RawMessage `json:"value"`
+		Count    int             `json:"count"`
+		First    int64           `json:"first"`
+		FirstStr string          `json:"firstdt"`
+		Last     int64           `json:"last"`
+		LastStr  string          `json:"lastdt"`
+	}
+
+	// valueTracker tracks the distinct values (and when they were seen) of a field path
+	valueTracker struct {
+		path    string
+		tracked map[string]*valueMeta
+	}
+
+	// DistinctAdder handles distinct values of a field
+	DistinctAdder struct {
+		objectAdder
+		path    string
+		values  *valueTracker
+		invalid []*problem
+	}
+)
+
+func newValueTracker(path string) *valueTracker {
+	return &valueTracker{path: path, tracked: make(map[string]*valueMeta)}
+}
+
+func (t *valueTracker) track(j map[string]json.RawMessage) {
+	v, ok := fieldValue(j, t.path)
+	if !ok {
+		return
+	}
+	tsRaw, ok := j[internal.TSKey]
+	if !ok {
+		return
+	}
+	dtRaw, ok := j[internal.DTKey]
+	if !ok {
+		return
+	}
+	d, ok := internal.JSONstring(dtRaw)
+	if !ok {
+		return
+	}
+	i, ok := internal.JSONint64(tsRaw)
+	if !ok {
+		return
+	}
+	s := string(v)
+	cur, ok := t.tracked[s]
+	if !ok {
+		t.tracked[s] = &valueMeta{Value: v, Count: 1, First: i, FirstStr: d, Last: i, LastStr: d}
+		return
+	}
+	cur.Count++
+	if i >= cur.Last {
+		cur.Last = i
+		cur.LastStr = d
+	}
+	if i <= cur.First {
+		cur.First = i
+		cur.FirstStr = d
+	}
+}
+
+func (t *valueTracker) values() map[string]*valueMeta {
+	if t == nil {
+		return nil
+	}
+	return t.tracked
+}
+
+// NewDistinctAdder creates a distinct adder from request parameters
+func NewDistinctAdder(req map[string][]string) *DistinctAdder {
+	d := &DistinctAdder{}
+	if p, ok := req[pathKey]; ok && len(p) > 0 {
+		d.path = strings.TrimSpace(p[0])
+	}
+	if len(d.path) == 0 {
+		d.invalid = append(d.invalid, newProblem(pathKey, "", fmt.Errorf("no distinct path given")))
+	}
+	return d
+}
+
+func (d *DistinctAdder) params() []string {
+	return []string{pathKey}
+}
+
+func (d *DistinctAdder) problems() []*problem {
+	return d.invalid
+}
+
+func (d *DistinctAdder) add(first bool, j map[string]json.RawMessage) {
+	if first {
+		d.values = newValueTracker(d.path)
+	}
+	d.values.track(j)
+}
+
+func (d *DistinctAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	results := []*valueMeta{}
+	for _, v := range d.values.values() {
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		x := results[i]
+		y := results[j]
+		if x.First == y.First {
+			return string(x.Value) < string(y.Value)
+		}
+		return x.First < y.First
+	})
+	w.Write(ctx.byteHeader)
+	for i, r := range results {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			internal.Errored("unable to marshal distinct value", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	followKey     = "follow"
+	timeoutKey    = "timeout"
+	defaultFollow = 30 * time.Second
+)
+
+func isTrue(value string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && b
+}
+
+func isFollow(req map[string][]string) bool {
+	v, ok := req[followKey]
+	return ok && len(v) > 0 && isTrue(v[0])
+}
+
+// parseTimeout parses a follow timeout (seconds or a duration)
+func parseTimeout(value string) (time.Duration, error) {
+	t := strings.TrimSpace(value)
+	d, err := parseSpan(t)
+	if err != nil {
+		i, err := strconv.Atoi(t)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timeout: %s", value)
+		}
+		d = time.Duration(i) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("timeout must not be negative")
+	}
+	return d, nil
+}
+
+// followTimeout is how long to wait, never more than the context allows (invalid timeouts are reported by the query)
+func followTimeout(ctx *Context, req map[string][]string) time.Duration {
+	v, ok := req[timeoutKey]
+	if !ok || len(v) == 0 {
+		return ctx.Follow
+	}
+	d, err := parseTimeout(v[0])
+	if err != nil || d > ctx.Follow {
+		return ctx.Follow
+	}
+	return d
+}
+
+// HandleFollow waits (up to the timeout) for matching data after the cursor and then writes it
+func HandleFollow(ctx *Context, h *internal.Configuration, req map[string][]string, d *DataWriter, done <-chan struct{}) bool {
+	deadline := time.Now().Add(followTimeout(ctx, req))
+	for {
+		var b bytes.Buffer
+		buffered := NewDataWriter(&b, nil)
+		buffered.format = d.format
+		buffered.limit = d.limit
+		// paging state is given once the (buffered) data is written
+		paged := false
+		cursor := ""
+		limited := false
+		buffered.Trailers(func(c string, l bool) {
+			paged = true
+			cursor = c
+			limited = l
+		})
+		if !Handle(ctx, req, h, buffered) {
+			d.errors = buffered.errors
+			d.warnings = buffered.warnings
+			d.failed = buffered.failed
+			return false
+		}
+		waiting := buffered.page == nil || buffered.page.Count == 0
+		if waiting && time.Now().Before(deadline) {
+			select {
+			case <-done:
+				return true
+			case <-time.After(ctx.poll()):
+				continue
+			}
+		}
+		d.format = buffered.format
+		d.setHeaders()
+		d.add(b.Bytes())
+		if paged && d.trailers != nil {
+			d.trailers(cursor, limited)
+		}
+		return true
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	formatKey     = "format"
+	jsonFormat    = "json"
+	ndjsonFormat  = "ndjson"
+	csvFormat     = "csv"
+	jsonContent   = "application/json"
+	ndjsonContent = "application/x-ndjson"
+	csvContent    = "text/csv"
+	newLine       = "\n"
+	// paging state (for formats without a page footer)
+	cursorTrailer  = "Armq-Cursor"
+	limitedTrailer = "Armq-Limited"
+)
+
+var (
+	// used for csv output when no projection is given
+	defaultColumns = []string{internal.IDKey, internal.TSKey, internal.DTKey, internal.VersKey, "file"}
+)
+
+func isFormat(format string) bool {
+	switch format {
+	case jsonFormat, ndjsonFormat, csvFormat:
+		return true
+	}
+	return false
+}
+
+// acceptFormat gets the output format from an 'Accept' header (if one is usable)
+func acceptFormat(accept string) string {
+	for _, a := range strings.Split(accept, ",") {
+		mime := strings.TrimSpace(strings.Split(a, ";")[0])
+		switch mime {
+		case ndjsonContent:
+			return ndjsonFormat
+		case csvContent:
+			return csvFormat
+		case jsonContent:
+			return jsonFormat
+		}
+	}
+	return ""
+}
+
+func parseFormat(format string) (string, error) {
+	f := strings.ToLower(strings.TrimSpace(format))
+	if !isFormat(f) {
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+	return f, nil
+}
+
+func (d *DataWriter) contentType() string {
+	switch d.format {
+	case ndjsonFormat:
+		return ndjsonContent
+	case csvFormat:
+		return csvContent
+	}
+	return jsonContent
+}
+
+// Trailers sets what is given the paging state of formats without a page footer (ndjson and csv)
+func (d *DataWriter) Trailers(t onTrailers) {
+	d.trailers = t
+}
+
+// trailed indicates the paging state is given as trailers
+func (d *DataWriter) trailed() bool {
+	return d.format == ndjsonFormat || d.format == csvFormat
+}
+
+// begin writes anything needed before any records
+func (d *DataWriter) begin(ctx *Context) {
+	switch d.format {
+	case ndjsonFormat:
+		return
+	case csvFormat:
+		if len(d.columns) == 0 {
+			d.columns = defaultColumns
+		}
+		if d.write {
+			d.csv = csv.NewWriter(d.writer)
+			d.csv.Write(d.columns)
+		}
+		return
+	}
+	d.addString(ctx.metaHeader)
+}
+
+// record writes a single record (first indicates if this is the first record written)
+func (d *DataWriter) record(first bool, obj map[string]json.RawMessage, b []byte) {
+	switch d.format {
+	case ndjsonFormat:
+		d.add(b)
+		d.addString(newLine)
+		return
+	case csvFormat:
+		if d.csv == nil {
+			return
+		}
+		row := []string{}
+		for _, c := range d.columns {
+			row = append(row, csvValue(obj, c))
+		}
+		d.csv.Write(row)
+		return
+	}
+	if !first {
+		d.addString(",")
+	}
+	d.add(b)
+}
+
+// end writes the trailing information after all records
+func (d *DataWriter) end(page *pageMeta) {
+	if d.csv != nil {
+		d.csv.Flush()
+		if err := d.csv.Error(); err != nil {
+			internal.Errored("unable to write csv", err)
+		}
+	}
+	if !d.trailed() {
+		d.addString(page.footer())
+		return
+	}
+	if d.trailers != nil {
+		d.trailers(page.Cursor, page.Limited)
+	}
+}
+
+func csvValue(obj map[string]json.RawMessage, path string) string {
+	v, ok := plainValue(obj, path)
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	return string(v)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	// URL endpoints
+	playersURL = "/players"
+)
+
+type (
+	// playerTag is a player's part in a tag
+	playerTag struct {
+		Tag      string         `json:"tag"`
+		First    int64          `json:"first"`
+		FirstStr string         `json:"firstdt"`
+		Last     int64          `json:"last"`
+		LastStr  string         `json:"lastdt"`
+		Events   map[string]int `json:"events"`
+	}
+
+	// playerInfo is what is known about a player (id)
+	playerInfo struct {
+		ID       string         `json:"id"`
+		Names    []string       `json:"names"`
+		First    int64          `json:"first"`
+		FirstStr string         `json:"firstdt"`
+		Last     int64          `json:"last"`
+		LastStr  string         `json:"lastdt"`
+		Tags     []string       `json:"tags"`
+		Events   map[string]int `json:"events"`
+		Details  []*playerTag   `json:"details,omitempty"`
+		names    map[string]struct{}
+		tags     map[string]*playerTag
+	}
+
+	// PlayerAdder tracks players (from player and event messages)
+	PlayerAdder struct {
+		objectAdder
+		id      string
+		players map[string]*playerInfo
+	}
+)
+
+// NewPlayerAdder creates a player adder (for a single player id, if given)
+func NewPlayerAdder(id string) *PlayerAdder {
+	return &PlayerAdder{id: id}
+}
+
+func (p *PlayerAdder) found() bool {
+	return len(p.players) > 0
+}
+
+func seen(ts int64, dt string, first, last *int64, firstStr, lastStr *string) {
+	if *first == 0 || ts < *first {
+		*first = ts
+		*firstStr = dt
+	}
+	if ts >= *last {
+		*last = ts
+		*lastStr = dt
+	}
+}
+
+func (p *PlayerAdder) add(first bool, j map[string]json.RawMessage) {
+	if first || p.players == nil {
+		p.players = make(map[string]*playerInfo)
+	}
+	id, ok := rawString(j, playerPath)
+	if !ok {
+		return
+	}
+	if len(p.id) > 0 && id != p.id {
+		return
+	}
+	ts, ok := internal.JSONint64(j[internal.TSKey])
+	if !ok {
+		return
+	}
+	dt, ok := internal.JSONstring(j[internal.DTKey])
+	if !ok {
+		return
+	}
+	cur, ok := p.players[id]
+	if !ok {
+		cur = &playerInfo{ID: id, Events: make(map[string]int)}
+		cur.names = make(map[string]struct{})
+		cur.tags = make(map[string]*playerTag)
+		p.players[id] = cur
+	}
+	seen(ts, dt, &cur.First, &cur.Last, &cur.FirstStr, &cur.LastStr)
+	if _, ok := fieldValue(j, joinPath); ok {
+		if n, ok := rawString(j, namePath); ok {
+			cur.names[n] = struct{}{}
+		}
+		return
+	}
+	tag, ok := rawString(j, tagPath)
+	if !ok {
+		return
+	}
+	t, ok := cur.tags[tag]
+	if !ok {
+		t = &playerTag{Tag: tag, Events: make(map[string]int)}
+		cur.tags[tag] = t
+	}
+	seen(ts, dt, &t.First, &t.Last, &t.FirstStr, &t.LastStr)
+	if e, ok := rawString(j, typePath); ok {
+		cur.Events[e]++
+		t.Events[e]++
+	}
+}
+
+func (p *PlayerAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	results := []*playerInfo{}
+	for _, v := range p.players {
+		v.Names = []string{}
+		for n := range v.names {
+			v.Names = append(v.Names, n)
+		}
+		sort.Strings(v.Names)
+		v.Tags = []string{}
+		for t, d := range v.tags {
+			v.Tags = append(v.Tags, t)
+			if len(p.id) > 0 {
+				v.Details = append(v.Details, d)
+			}
+		}
+		sort.Strings(v.Tags)
+		sort.Slice(v.Details, func(i, j int) bool {
+			return v.Details[i].First < v.Details[j].First
+		})
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	w.Write(ctx.byteHeader)
+	for i, r := range results {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			internal.Errored("unable to marshal player", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
+
+func playerRequest(ctx *Context, conf *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	id := pathID(r, playersURL)
+	detailRequest(ctx, conf, w, r, NewPlayerAdder(id), id)
+}
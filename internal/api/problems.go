@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	strictKey = "strict"
+	unknownOp = "unknown parameter"
+)
+
+var (
+	// parameters understood by any data request
+	queryKeys = []string{filterKey, "start", "end", limitKey, "skip", "files", "startdate", "enddate", "seek", projectKey, formatKey, sortKey, cursorKey, followKey, timeoutKey, strictKey, tzKey, shapeKey}
+)
+
+type (
+	// problem is an issue with a request parameter
+	problem struct {
+		Param   string `json:"param"`
+		Value   string `json:"value,omitempty"`
+		Message string `json:"message"`
+	}
+
+	problemResponse struct {
+		Errors   []*problem `json:"errors"`
+		Warnings []*problem `json:"warnings,omitempty"`
+	}
+
+	// paramAdder is an object adder that takes (and validates) its own parameters
+	paramAdder interface {
+		params() []string
+		problems() []*problem
+	}
+)
+
+func newProblem(param, value string, err error) *problem {
+	return &problem{Param: param, Value: value, Message: err.Error()}
+}
+
+// unknownParams warns about any parameters that are not used
+func unknownParams(req map[string][]string, known []string) []*problem {
+	lookup := make(map[string]struct{})
+	for _, k := range known {
+		lookup[k] = struct{}{}
+	}
+	keys := []string{}
+	for k := range req {
+		if _, ok := lookup[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	results := []*problem{}
+	for _, k := range keys {
+		results = append(results, &problem{Param: k, Message: unknownOp})
+	}
+	return results
+}
+
+// WriteErrors writes the reasons a request could not be handled
+func (d *DataWriter) WriteErrors(w io.Writer) {
+	errs := d.errors
+	if len(errs) == 0 {
+		errs = []*problem{{Message: "unable to handle request"}}
+	}
+	b, err := json.Marshal(&problemResponse{Errors: errs, Warnings: d.warnings})
+	if err != nil {
+		internal.Errored("unable to marshal errors", err)
+		return
+	}
+	w.Write(b)
+}
+
+func writeErrors(w http.ResponseWriter, d *DataWriter) {
+	status := http.StatusBadRequest
+	if d.failed {
+		status = http.StatusInternalServerError
+	}
+	writeStatus(w, d, status)
+}
+
+func writeStatus(w http.ResponseWriter, d *DataWriter, status int) {
+	w.Header().Set("Content-Type", jsonContent)
+	w.WriteHeader(status)
+	d.WriteErrors(w)
+}
+
+func logProblems(problems []*problem) {
+	for _, p := range problems {
+		internal.Info(fmt.Sprintf("request problem: %s (%s) %s", p.Param, p.Value, p.Message))
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+type (
+	// query is a parsed data request
+	query struct {
+		filters   []*dataFilter
+		limit     int
+		skip      int
+		startDate time.Time
+		endDate   time.Time
+		zone      *time.Location
+		files     string
+		seek      bool
+		follow    bool
+		token     string
+		project   projection
+		columns   []string
+		format    string
+		sortBy    string
+		order     *recordOrder
+		cursor    *pageCursor
+		strict    bool
+		flat      bool
+		errors    []*problem
+		warnings  []*problem
+	}
+)
+
+func parseQuery(ctx *Context, req map[string][]string, limit int) *query {
+	q := &query{limit: limit, strict: !ctx.Lenient, zone: ctx.zone()}
+	invalid := func(param, value string, err error) {
+		q.errors = append(q.errors, newProblem(param, value, err))
+	}
+	// the zone is needed to parse any other times
+	if z, ok := req[tzKey]; ok && len(z) > 0 {
+		loc, err := parseZone(z[0])
+		if err != nil {
+			invalid(tzKey, z[0], err)
+		} else {
+			q.zone = loc
+		}
+	}
+	now := time.Now()
+	keys := []string{}
+	for k := range req {
+		keys = append(keys, k)
+	}
+	// problems are reported in a consistent order
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := req[k]
+		if len(p) == 0 {
+			continue
+		}
+		switch k {
+		case filterKey:
+			for _, val := range p {
+				f, err := parseFilter(val, ctx.Convert)
+				if err != nil {
+					invalid(k, val, err)
+					continue
+				}
+				q.filters = append(q.filters, f)
+			}
+
+		case "start":
+			fallthrough
+		case "end":
+			mode := endStringOp
+			if k == "start" {
+				mode = startStringOp
+			}
+			t, err := parseTime(p[0], q.zone, now)
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			f, err := timeFilter(mode, epochMillis(t), ctx.Convert)
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.filters = append(q.filters, f)
+		case limitKey:
+			i, err := strconv.Atoi(p[0])
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.limit = i
+		case "skip":
+			i, err := strconv.Atoi(p[0])
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			if i > 0 {
+				q.skip = i
+			}
+		case "files":
+			q.files = strings.TrimSpace(p[0])
+		case "startdate", "enddate":
+			t, err := parseTime(p[0], q.zone, now)
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			if k == "startdate" {
+				q.startDate = t
+			} else {
+				q.endDate = t
+			}
+		case "seek":
+			q.seek = true
+		case projectKey:
+			q.project = parseProjection(p)
+			q.columns = projectionPaths(p)
+		case formatKey:
+			f, err := parseFormat(p[0])
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.format = f
+		case sortKey:
+			o, err := parseOrder(p[0], ctx.Convert)
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.sortBy = p[0]
+			q.order = o
+		case cursorKey:
+			q.token = strings.TrimSpace(p[0])
+		case followKey:
+			q.follow = isTrue(p[0])
+		case timeoutKey:
+			if _, err := parseTimeout(p[0]); err != nil {
+				invalid(k, p[0], err)
+			}
+		case shapeKey:
+			f, err := parseShape(p[0])
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.flat = f
+		case strictKey:
+			b, err := strconv.ParseBool(strings.TrimSpace(p[0]))
+			if err != nil {
+				invalid(k, p[0], err)
+				continue
+			}
+			q.strict = b
+		}
+	}
+	if len(q.token) > 0 {
+		c, err := parseCursor(q.token, q.sortBy)
+		if err != nil {
+			invalid(cursorKey, q.token, err)
+		} else {
+			q.cursor = c
+			// the cursor is already past any skipped records
+			q.skip = 0
+		}
+	}
+	if q.follow && q.cursor == nil {
+		// nothing to follow from, start with the latest data
+		q.seek = true
+	}
+	return q
+}
+
+// output gets the record output (applying any projection)
+func (q *query) output(obj map[string]json.RawMessage, b []byte) ([]byte, bool) {
+	var out interface{}
+	if q.flat {
+		f, ok := flatten(obj)
+		if !ok {
+			return nil, false
+		}
+		obj = f
+		out = f
+	}
+	if q.project != nil {
+		out = q.project.apply(obj)
+	}
+	if out == nil {
+		return b, true
+	}
+	r, err := json.Marshal(out)
+	if err != nil {
+		internal.Errored("unable to project object", err)
+		return nil, false
+	}
+	return r, true
+}
+
+// matches indicates if the object passes all filters
+func (q *query) matches(obj map[string]json.RawMessage) bool {
+	for _, d := range q.filters {
+		if !matchField(obj, d) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	// URL endpoints
+	recordsURL = "/records"
+)
+
+var (
+	// parameters understood by a single record request
+	recordKeys = []string{projectKey, strictKey, shapeKey}
+)
+
+// recordPaths gets where a record may be, the day of its timestamp (then the days around it), then any other day (nearest first) as a busy worker keeps writing to the day it started on
+func recordPaths(ctx *Context, id *recordID, name string) []string {
+	ts := time.Unix(0, id.timestamp*int64(time.Millisecond)).In(ctx.zone())
+	paths := []string{}
+	checked := make(map[string]struct{})
+	for _, offset := range []int{0, 1, -1} {
+		day := ts.AddDate(0, 0, offset).Format(dayFormat)
+		checked[day] = struct{}{}
+		paths = append(paths, filepath.Join(ctx.Directory, day, name))
+	}
+	dirs, err := ioutil.ReadDir(ctx.Directory)
+	if err != nil {
+		internal.Errored("unable to read dir", err)
+		return paths
+	}
+	start := getDate(ts, 0, ctx.zone())
+	distance := func(d os.FileInfo) time.Duration {
+		diff := dayOf(d, ctx.zone()).Sub(start)
+		if diff < 0 {
+			return -diff
+		}
+		return diff
+	}
+	others := []os.FileInfo{}
+	for _, d := range dirs {
+		if _, ok := checked[d.Name()]; ok || !d.IsDir() {
+			continue
+		}
+		others = append(others, d)
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return distance(others[i]) < distance(others[j])
+	})
+	for _, d := range others {
+		paths = append(paths, filepath.Join(ctx.Directory, d.Name(), name))
+	}
+	return paths
+}
+
+// HandleRecord writes a single record by id, indicating if the record was found
+func HandleRecord(ctx *Context, name string, req map[string][]string, h *internal.Configuration, writer *DataWriter) (bool, bool) {
+	started := time.Now()
+	q := parseQuery(ctx, req, 0)
+	q.warnings = append(q.warnings, unknownParams(req, recordKeys)...)
+	id, ok := parseRecordID(name)
+	if !ok || filepath.Base(name) != name {
+		q.errors = append(q.errors, &problem{Param: internal.IDKey, Value: name, Message: "invalid record id"})
+		q.strict = true
+	}
+	if len(q.errors) > 0 {
+		logProblems(q.errors)
+		if q.strict {
+			writer.errors = q.errors
+			writer.warnings = q.warnings
+			return true, false
+		}
+		q.warnings = append(q.warnings, q.errors...)
+	}
+	page := &pageMeta{Warnings: q.warnings}
+	for _, p := range recordPaths(ctx, id, name) {
+		if !internal.PathExists(p) {
+			continue
+		}
+		page.Scanned++
+		obj, b := ctx.load(p, h)
+		if obj == nil {
+			page.Failed++
+			continue
+		}
+		out, ok := q.output(obj, b)
+		if !ok {
+			page.Failed++
+			continue
+		}
+		page.Count = 1
+		page.Matched = 1
+		page.Elapsed = int64(time.Since(started) / time.Millisecond)
+		writer.setHeaders()
+		writer.begin(ctx)
+		writer.record(true, obj, out)
+		writer.end(page)
+		writer.page = page
+		return true, true
+	}
+	writer.errors = []*problem{{Param: internal.IDKey, Value: name, Message: fmt.Sprintf("not found: %s", name)}}
+	writer.warnings = q.warnings
+	return false, false
+}
+
+func recordRequest(ctx *Context, conf *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	d := newWebDataWriter(w)
+	found, ok := HandleRecord(ctx, pathID(r, recordsURL), r.URL.Query(), conf, d)
+	if !found {
+		writeStatus(w, d, http.StatusNotFound)
+		return
+	}
+	if !ok {
+		writeErrors(w, d)
+	}
+}
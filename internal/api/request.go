@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	// largest (POST) request body accepted
+	maxBody = 1 << 20
+
+	// URL endpoints
+	queryURL = "/query"
+)
+
+type (
+	// QueryFilter is a single (typed) filter
+	QueryFilter struct {
+		Field string          `json:"field"`
+		Op    string          `json:"op"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	// QueryRequest is a JSON data request, it mirrors the query parameters of a GET request
+	QueryRequest struct {
+		Filters   []QueryFilter   `json:"filters"`
+		Start     json.RawMessage `json:"start"`
+		End       json.RawMessage `json:"end"`
+		StartDate string          `json:"startdate"`
+		EndDate   string          `json:"enddate"`
+		TZ        string          `json:"tz"`
+		Limit     *int            `json:"limit"`
+		Skip      int             `json:"skip"`
+		Files     string          `json:"files"`
+		Seek      bool            `json:"seek"`
+		Cursor    string          `json:"cursor"`
+		Fields    []string        `json:"fields"`
+		Sort      string          `json:"sort"`
+		Format    string          `json:"format"`
+		Follow    bool            `json:"follow"`
+		Timeout   json.RawMessage `json:"timeout"`
+		Strict    *bool           `json:"strict"`
+		Shape     string          `json:"shape"`
+	}
+)
+
+// DecodeQuery reads a JSON query request
+func DecodeQuery(r io.Reader) (*QueryRequest, error) {
+	q := &QueryRequest{}
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// rawValue converts a JSON value to how it would be given as a query parameter
+func rawValue(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(v))
+}
+
+func hasValue(v json.RawMessage) bool {
+	return len(v) > 0 && string(v) != nullJSON
+}
+
+// Values converts the request to query parameters
+func (q *QueryRequest) Values() map[string][]string {
+	req := make(map[string][]string)
+	set := func(key, value string) {
+		if len(value) > 0 {
+			req[key] = []string{value}
+		}
+	}
+	for _, f := range q.Filters {
+		req[filterKey] = append(req[filterKey], strings.Join([]string{f.Field, f.Op, rawValue(f.Value)}, filterDelimiter))
+	}
+	if hasValue(q.Start) {
+		set("start", rawValue(q.Start))
+	}
+	if hasValue(q.End) {
+		set("end", rawValue(q.End))
+	}
+	set("startdate", q.StartDate)
+	set("enddate", q.EndDate)
+	set(tzKey, q.TZ)
+	if q.Limit != nil {
+		set(limitKey, strconv.Itoa(*q.Limit))
+	}
+	if q.Skip != 0 {
+		set("skip", strconv.Itoa(q.Skip))
+	}
+	set("files", q.Files)
+	if q.Seek {
+		set("seek", "true")
+	}
+	set(cursorKey, q.Cursor)
+	if len(q.Fields) > 0 {
+		req[projectKey] = q.Fields
+	}
+	set(sortKey, q.Sort)
+	set(formatKey, q.Format)
+	if q.Follow {
+		set(followKey, "true")
+	}
+	if hasValue(q.Timeout) {
+		set(timeoutKey, rawValue(q.Timeout))
+	}
+	set(shapeKey, q.Shape)
+	if q.Strict != nil {
+		set(strictKey, strconv.FormatBool(*q.Strict))
+	}
+	return req
+}
+
+func postRequest(ctx *Context, h *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	d := newWebDataWriter(w)
+	q, err := DecodeQuery(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		d.errors = []*problem{{Message: fmt.Sprintf("invalid query: %v", err)}}
+		writeErrors(w, d)
+		return
+	}
+	webQuery(ctx, h, w, r, d, q.Values())
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	inactiveEnd = "inactive"
+
+	// URL endpoints
+	sessionsURL = "/sessions"
+)
+
+var (
+	startPath = internal.FieldKey + fieldNamespace + "start"
+	eventPath = internal.FieldKey + fieldNamespace + "event"
+	namePath  = rawPath("name")
+	joinPath  = internal.FieldKey + fieldNamespace + "player"
+)
+
+type (
+	// sessionMessage is a (normalized) message within a session
+	sessionMessage struct {
+		ID     string          `json:"id"`
+		TS     int64           `json:"ts"`
+		DT     string          `json:"dt"`
+		Fields json.RawMessage `json:"fields"`
+		player string
+	}
+
+	sessionEnd struct {
+		TS     int64  `json:"ts"`
+		DT     string `json:"dt"`
+		Reason string `json:"reason"`
+	}
+
+	// session is a run (tag) from start to end
+	session struct {
+		Tag      string            `json:"tag"`
+		Start    *sessionMessage   `json:"start"`
+		Replay   *sessionMessage   `json:"replay"`
+		Players  []*sessionMessage `json:"players"`
+		Events   []*sessionMessage `json:"events"`
+		End      *sessionEnd       `json:"end"`
+		Active   bool              `json:"active"`
+		Duration int64             `json:"duration"`
+		first    *sessionMessage
+		last     *sessionMessage
+		events   int
+	}
+
+	// sessionSummary is a session when listing sessions
+	sessionSummary struct {
+		Tag      string      `json:"tag"`
+		Start    int64       `json:"start"`
+		StartStr string      `json:"startdt"`
+		End      *sessionEnd `json:"end"`
+		Active   bool        `json:"active"`
+		Duration int64       `json:"duration"`
+		Players  int         `json:"players"`
+		Events   int         `json:"events"`
+		World    string      `json:"world,omitempty"`
+		Mission  string      `json:"mission,omitempty"`
+	}
+
+	// SessionAdder assembles sessions from start, replay, player, and event messages
+	SessionAdder struct {
+		objectAdder
+		tag      string
+		sessions map[string]*session
+		joined   []*sessionMessage
+		active   *bool
+		idle     time.Duration
+		invalid  []*problem
+	}
+)
+
+// NewSessionAdder creates a session adder (for a single tag, if given) from request parameters
+func NewSessionAdder(req map[string][]string, tag string) *SessionAdder {
+	s := &SessionAdder{tag: tag}
+	s.active, s.idle, s.invalid = parseActivity(req)
+	return s
+}
+
+func (s *SessionAdder) params() []string {
+	return []string{activeKey, idleKey}
+}
+
+func (s *SessionAdder) problems() []*problem {
+	return s.invalid
+}
+
+func newSessionMessage(j map[string]json.RawMessage) (*sessionMessage, bool) {
+	id, ok := internal.JSONstring(j[internal.IDKey])
+	if !ok {
+		return nil, false
+	}
+	ts, ok := internal.JSONint64(j[internal.TSKey])
+	if !ok {
+		return nil, false
+	}
+	dt, ok := internal.JSONstring(j[internal.DTKey])
+	if !ok {
+		return nil, false
+	}
+	return &sessionMessage{ID: id, TS: ts, DT: dt, Fields: j[internal.FieldKey]}, true
+}
+
+func sortMessages(messages []*sessionMessage) {
+	sort.Slice(messages, func(i, j int) bool {
+		x := messages[i]
+		y := messages[j]
+		if x.TS == y.TS {
+			return x.ID < y.ID
+		}
+		return x.TS < y.TS
+	})
+}
+
+func (s *SessionAdder) add(first bool, j map[string]json.RawMessage) {
+	if first || s.sessions == nil {
+		s.sessions = make(map[string]*session)
+		s.joined = nil
+	}
+	if _, ok := fieldValue(j, joinPath); ok {
+		m, ok := newSessionMessage(j)
+		if !ok {
+			return
+		}
+		if p, ok := rawString(j, playerPath); ok {
+			m.player = p
+		}
+		s.joined = append(s.joined, m)
+		return
+	}
+	_, replay := fieldValue(j, replayPath)
+	tagged := tagPath
+	if replay {
+		tagged = missionPath
+	}
+	tag, ok := rawString(j, tagged)
+	if !ok {
+		return
+	}
+	m, ok := newSessionMessage(j)
+	if !ok {
+		return
+	}
+	cur, ok := s.sessions[tag]
+	if !ok {
+		cur = &session{Tag: tag, Players: []*sessionMessage{}, Events: []*sessionMessage{}}
+		s.sessions[tag] = cur
+	}
+	if cur.first == nil || m.TS < cur.first.TS {
+		cur.first = m
+	}
+	if cur.last == nil || m.TS >= cur.last.TS {
+		cur.last = m
+	}
+	if replay {
+		cur.Replay = m
+		return
+	}
+	if _, ok := fieldValue(j, startPath); ok {
+		cur.Start = m
+		return
+	}
+	if _, ok := fieldValue(j, eventPath); ok {
+		cur.events++
+		// only a single session keeps all events (other sessions are only kept for when players joined)
+		if len(s.tag) > 0 && tag == s.tag {
+			cur.Events = append(cur.Events, m)
+		}
+	}
+}
+
+// found indicates if any session was assembled
+func (s *SessionAdder) found() bool {
+	if len(s.tag) > 0 {
+		_, ok := s.sessions[s.tag]
+		return ok
+	}
+	return len(s.sessions) > 0
+}
+
+// join adds players to the session they joined, players joining (up to idle) before a start join the next session
+func (s *SessionAdder) join() {
+	for _, p := range s.joined {
+		var next *session
+		joined := false
+		for _, v := range s.sessions {
+			if p.TS >= v.first.TS && p.TS <= v.last.TS {
+				v.Players = append(v.Players, p)
+				joined = true
+				continue
+			}
+			if v.first.TS > p.TS && v.first.TS-p.TS <= int64(s.idle/time.Millisecond) {
+				if next == nil || v.first.TS < next.first.TS || (v.first.TS == next.first.TS && v.Tag < next.Tag) {
+					next = v
+				}
+			}
+		}
+		if !joined && next != nil {
+			next.Players = append(next.Players, p)
+		}
+	}
+}
+
+// assemble gets the sessions (ordered by start) with joined players and how they ended
+func (s *SessionAdder) assemble() []*session {
+	since := activeSince(s.idle)
+	s.join()
+	results := []*session{}
+	for _, v := range s.sessions {
+		if len(s.tag) > 0 && v.Tag != s.tag {
+			continue
+		}
+		v.Duration = v.last.TS - v.first.TS
+		v.Active = v.last.TS >= since
+		if !v.Active {
+			v.End = &sessionEnd{TS: v.last.TS, DT: v.last.DT, Reason: inactiveEnd}
+		}
+		if s.active != nil && *s.active != v.Active {
+			continue
+		}
+		sortMessages(v.Players)
+		sortMessages(v.Events)
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		x := results[i]
+		y := results[j]
+		if x.first.TS == y.first.TS {
+			return x.Tag < y.Tag
+		}
+		return x.first.TS < y.first.TS
+	})
+	return results
+}
+
+func (v *session) summary() *sessionSummary {
+	r := &sessionSummary{Tag: v.Tag, Start: v.first.TS, StartStr: v.first.DT, End: v.End, Active: v.Active, Duration: v.Duration, Events: v.events}
+	players := make(map[string]struct{})
+	for _, p := range v.Players {
+		players[p.player] = struct{}{}
+	}
+	r.Players = len(players)
+	if v.Replay != nil {
+		obj := make(map[string]json.RawMessage)
+		obj[internal.FieldKey] = v.Replay.Fields
+		r.Mission, _ = rawString(obj, missionPath)
+		r.World, _ = rawString(obj, worldPath)
+	}
+	return r
+}
+
+func (s *SessionAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	w.Write(ctx.byteHeader)
+	for i, v := range s.assemble() {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		var obj interface{}
+		obj = v
+		if len(s.tag) == 0 {
+			obj = v.summary()
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			internal.Errored("unable to marshal session", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
+
+func sessionRequest(ctx *Context, conf *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	tag := pathID(r, sessionsURL)
+	detailRequest(ctx, conf, w, r, NewSessionAdder(r.URL.Query(), tag), tag)
+}
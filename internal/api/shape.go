@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	shapeKey    = "shape"
+	flatShape   = "flat"
+	entryShape  = "entry"
+	entryMarker = "\"jsontype\""
+)
+
+func parseShape(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case flatShape:
+		return true, nil
+	case entryShape:
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown shape: %s", value)
+}
+
+// isEntryKey indicates a path segment is part of an entry (e.g. raw)
+func isEntryKey(segment string) bool {
+	switch segment {
+	case "jsontype", internal.NotJSON, internal.ArrayJSON, internal.ObjJSON, internal.ValueKey:
+		return true
+	}
+	return false
+}
+
+// plainEntry unwraps a (jsontype) entry to plain JSON
+func plainEntry(v json.RawMessage) (json.RawMessage, bool) {
+	t := bytes.TrimSpace(v)
+	if len(t) == 0 || t[0] != '{' || !bytes.Contains(t, []byte(entryMarker)) {
+		return nil, false
+	}
+	e := &internal.Entry{}
+	if err := json.Unmarshal(t, e); err != nil || len(e.Type) == 0 {
+		return nil, false
+	}
+	return e.Plain(), true
+}
+
+// plainValue gets the (first) value at the given path, unwrapping an entry (e.g. for flattened paths)
+func plainValue(obj map[string]json.RawMessage, field string) (json.RawMessage, bool) {
+	v, ok := fieldValue(obj, field)
+	if !ok {
+		return nil, false
+	}
+	if p, ok := plainEntry(v); ok {
+		return p, true
+	}
+	return v, true
+}
+
+// flatten turns the (entry) fields of a record into plain JSON
+func flatten(obj map[string]json.RawMessage) (map[string]json.RawMessage, bool) {
+	v, ok := obj[internal.FieldKey]
+	if !ok {
+		return obj, true
+	}
+	var fields map[string]*internal.Entry
+	if err := json.Unmarshal(v, &fields); err != nil {
+		internal.Errored("unable to read fields to flatten", err)
+		return nil, false
+	}
+	flat := make(map[string]json.RawMessage)
+	for k, e := range fields {
+		flat[k] = e.Plain()
+	}
+	b, err := json.Marshal(flat)
+	if err != nil {
+		internal.Errored("unable to flatten fields", err)
+		return nil, false
+	}
+	r := make(map[string]json.RawMessage)
+	for k, o := range obj {
+		r[k] = o
+	}
+	r[internal.FieldKey] = b
+	return r, true
+}
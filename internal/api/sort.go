@@ -0,0 +1,261 @@
+package api
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	sortKey        = "sort"
+	descendingSort = "-"
+	idDelimiter    = "."
+	idParts        = 4
+)
+
+type (
+	// recordID is a parsed record identifier (<start>.<ts>.<worker>.<count>)
+	recordID struct {
+		start     string
+		timestamp int64
+		worker    string
+		count     int
+	}
+
+	recordOrder struct {
+		field string
+		desc  bool
+		conv  internal.TypeConv
+	}
+
+	record struct {
+		path string
+		obj  map[string]json.RawMessage
+		raw  []byte
+		key  json.RawMessage
+		has  bool
+	}
+
+	recordFile struct {
+		path string
+		id   *recordID
+	}
+
+	// files written by a single worker (in a single day), counts restart when a worker resets
+	fileStream struct {
+		files []*recordFile
+		idx   int
+	}
+
+	streamHeap struct {
+		streams []*fileStream
+		desc    bool
+	}
+)
+
+func parseRecordID(id string) (*recordID, bool) {
+	parts := strings.Split(id, idDelimiter)
+	if len(parts) != idParts {
+		return nil, false
+	}
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	count, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, false
+	}
+	return &recordID{start: parts[0], timestamp: ts, worker: parts[2], count: count}, true
+}
+
+// before orders ids (of a worker) by timestamp then count
+func (id *recordID) before(other *recordID) bool {
+	if id.timestamp == other.timestamp {
+		return id.count < other.count
+	}
+	return id.timestamp < other.timestamp
+}
+
+func parseOrder(value string, mapping map[string]internal.TypeConv) (*recordOrder, error) {
+	field := strings.TrimSpace(value)
+	o := &recordOrder{}
+	if strings.HasPrefix(field, descendingSort) {
+		o.desc = true
+		field = strings.TrimPrefix(field, descendingSort)
+	}
+	o.field = field
+	if field == internal.TSKey {
+		o.conv = Int64Conv
+		return o, nil
+	}
+	t, ok := converter(mapping, field)
+	if !ok {
+		return nil, fmt.Errorf("sort field unknown: %s", field)
+	}
+	o.conv = t
+	return o, nil
+}
+
+// byName indicates the ordering can be done from the file names (no need to load records)
+func (o *recordOrder) byName() bool {
+	return o.field == internal.TSKey
+}
+
+func (h *streamHeap) Len() int {
+	return len(h.streams)
+}
+
+func (h *streamHeap) Less(i, j int) bool {
+	a := h.streams[i].current()
+	b := h.streams[j].current()
+	if a.id.timestamp == b.id.timestamp {
+		if h.desc {
+			return a.path > b.path
+		}
+		return a.path < b.path
+	}
+	if h.desc {
+		return a.id.timestamp > b.id.timestamp
+	}
+	return a.id.timestamp < b.id.timestamp
+}
+
+func (h *streamHeap) Swap(i, j int) {
+	h.streams[i], h.streams[j] = h.streams[j], h.streams[i]
+}
+
+func (h *streamHeap) Push(x interface{}) {
+	h.streams = append(h.streams, x.(*fileStream))
+}
+
+func (h *streamHeap) Pop() interface{} {
+	last := len(h.streams) - 1
+	s := h.streams[last]
+	h.streams = h.streams[:last]
+	return s
+}
+
+func (s *fileStream) current() *recordFile {
+	return s.files[s.idx]
+}
+
+// files will merge the per-worker file streams by the timestamp in the file name
+func (o *recordOrder) files(paths []string) []string {
+	streams := make(map[string]*fileStream)
+	keys := []string{}
+	unknown := []string{}
+	for _, p := range paths {
+		id, ok := parseRecordID(filepath.Base(p))
+		if !ok {
+			unknown = append(unknown, p)
+			continue
+		}
+		key := filepath.Join(filepath.Dir(p), id.start+idDelimiter+id.worker)
+		s, ok := streams[key]
+		if !ok {
+			s = &fileStream{}
+			streams[key] = s
+			keys = append(keys, key)
+		}
+		s.files = append(s.files, &recordFile{path: p, id: id})
+	}
+	h := &streamHeap{desc: o.desc}
+	for _, k := range keys {
+		s := streams[k]
+		sort.SliceStable(s.files, func(i, j int) bool {
+			if o.desc {
+				return s.files[j].id.before(s.files[i].id)
+			}
+			return s.files[i].id.before(s.files[j].id)
+		})
+		h.streams = append(h.streams, s)
+	}
+	heap.Init(h)
+	results := []string{}
+	for h.Len() > 0 {
+		s := h.streams[0]
+		results = append(results, s.current().path)
+		s.idx++
+		if s.idx == len(s.files) {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return append(results, unknown...)
+}
+
+func compareValues(conv internal.TypeConv, a, b []byte) int {
+	switch conv {
+	case Int64Conv:
+		x, _ := internal.JSONint64(a)
+		y, _ := internal.JSONint64(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case IntConv:
+		x, _ := internal.JSONint(a)
+		y, _ := internal.JSONint(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case Float64Conv:
+		x, _ := internal.JSONfloat64(a)
+		y, _ := internal.JSONfloat64(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case StrConv, TimeConv:
+		x, _ := internal.JSONstring(a)
+		y, _ := internal.JSONstring(b)
+		return strings.Compare(x, y)
+	case BoolConv:
+		x, _ := internal.JSONbool(a)
+		y, _ := internal.JSONbool(b)
+		switch {
+		case !x && y:
+			return -1
+		case x && !y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func (o *recordOrder) newRecord(path string, obj map[string]json.RawMessage, b []byte) *record {
+	r := &record{path: path, obj: obj, raw: b}
+	r.key, r.has = plainValue(obj, o.field)
+	return r
+}
+
+// sort will order (typed) records, records without the field are always last
+func (o *recordOrder) sort(records []*record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a := records[i]
+		b := records[j]
+		if !a.has || !b.has {
+			return a.has && !b.has
+		}
+		c := compareValues(o.conv, a.key, b.key)
+		if o.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
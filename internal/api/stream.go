@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	lastEventHeader = "Last-Event-ID"
+	eventContent    = "text/event-stream"
+	keepAlive       = ": keep-alive\n\n"
+	// polls without sending anything before a keep-alive
+	keepAlivePolls = 15
+	// attempts to read a (possibly still being written) file
+	streamRetries = 3
+	// how long a (non-latest) day directory is watched without changes
+	retireAfter = time.Hour
+	dayFormat   = internal.DayFormat
+
+	// URL endpoints
+	streamURL = "/stream"
+)
+
+type (
+	watchedDir struct {
+		seen map[string]struct{}
+		idle int
+	}
+
+	// streamWatcher detects new record files in the output directories
+	streamWatcher struct {
+		ctx       *Context
+		since     string
+		dirs      map[string]*watchedDir
+		retired   map[string]struct{}
+		fails     map[string]int
+		after     *recordID
+		afterName string
+		retire    int
+	}
+)
+
+func newStreamWatcher(ctx *Context, lastID string) *streamWatcher {
+	s := &streamWatcher{ctx: ctx}
+	s.dirs = make(map[string]*watchedDir)
+	s.retired = make(map[string]struct{})
+	s.fails = make(map[string]int)
+	s.retire = int(retireAfter / ctx.poll())
+	if id, ok := parseRecordID(lastID); ok {
+		s.after = id
+		s.afterName = lastID
+		// the receiver started before writing the record (so this is the earliest day)
+		if len(id.start) >= len(dayFormat) {
+			s.since = id.start[0:len(dayFormat)]
+		}
+	}
+	return s
+}
+
+func (s *streamWatcher) isAfter(name string) bool {
+	id, ok := parseRecordID(name)
+	if !ok {
+		return false
+	}
+	if id.timestamp == s.after.timestamp {
+		return name > s.afterName
+	}
+	return id.timestamp > s.after.timestamp
+}
+
+func (s *streamWatcher) watching(dir string) *watchedDir {
+	w, ok := s.dirs[dir]
+	if !ok {
+		w = &watchedDir{seen: make(map[string]struct{})}
+		s.dirs[dir] = w
+	}
+	return w
+}
+
+func (s *streamWatcher) mark(path string) {
+	s.watching(filepath.Base(filepath.Dir(path))).seen[filepath.Base(path)] = struct{}{}
+	delete(s.fails, path)
+}
+
+func (s *streamWatcher) failed(path string) {
+	s.fails[path]++
+	if s.fails[path] >= streamRetries {
+		internal.Info(fmt.Sprintf("unable to stream: %s", path))
+		s.mark(path)
+	}
+}
+
+// poll gets any new files (in timestamp order), the initial poll only resumes (after a record)
+func (s *streamWatcher) poll(initial bool) []string {
+	dirs, err := ioutil.ReadDir(s.ctx.Directory)
+	if err != nil {
+		internal.Errored("unable to read dir", err)
+		return nil
+	}
+	if initial && len(s.since) == 0 {
+		for _, d := range dirs {
+			if d.IsDir() {
+				s.since = d.Name()
+			}
+		}
+	}
+	latest := ""
+	files := []string{}
+	for _, d := range dirs {
+		name := d.Name()
+		if !d.IsDir() || name < s.since {
+			continue
+		}
+		if _, ok := s.retired[name]; ok {
+			continue
+		}
+		latest = name
+		p := filepath.Join(s.ctx.Directory, name)
+		f, err := ioutil.ReadDir(p)
+		if err != nil {
+			internal.Errored("unable to read stream subdir", err)
+			continue
+		}
+		w := s.watching(name)
+		found := false
+		for _, file := range f {
+			n := file.Name()
+			if _, ok := w.seen[n]; ok {
+				continue
+			}
+			if initial && (s.after == nil || !s.isAfter(n)) {
+				w.seen[n] = struct{}{}
+				continue
+			}
+			found = true
+			files = append(files, filepath.Join(p, n))
+		}
+		if found {
+			w.idle = 0
+		} else {
+			w.idle++
+		}
+	}
+	for name, w := range s.dirs {
+		if name != latest && w.idle > s.retire {
+			delete(s.dirs, name)
+			s.retired[name] = struct{}{}
+		}
+	}
+	order := &recordOrder{field: internal.TSKey, conv: Int64Conv}
+	return order.files(files)
+}
+
+// HandleStream writes (server-sent) events for new records, resuming after the last event id, until done
+func HandleStream(ctx *Context, req map[string][]string, lastID string, h *internal.Configuration, writer *DataWriter, flush func(), done <-chan struct{}) bool {
+	q := parseQuery(ctx, req, 0)
+	if len(q.errors) > 0 {
+		logProblems(q.errors)
+		if q.strict {
+			writer.errors = q.errors
+			writer.warnings = unknownParams(req, queryKeys)
+			return false
+		}
+	}
+	watcher := newStreamWatcher(ctx, lastID)
+	writer.setHeaders()
+	flush()
+	ticker := time.NewTicker(ctx.poll())
+	defer ticker.Stop()
+	initial := true
+	idle := 0
+	for {
+		sent := false
+		for _, p := range watcher.poll(initial) {
+			obj, b := loadFile(p, h)
+			if obj == nil {
+				watcher.failed(p)
+				continue
+			}
+			watcher.mark(p)
+			if !q.matches(obj) {
+				continue
+			}
+			b, ok := q.output(obj, b)
+			if !ok {
+				continue
+			}
+			var data bytes.Buffer
+			if err := json.Compact(&data, b); err != nil {
+				internal.Errored("unable to stream record", err)
+				continue
+			}
+			writer.addString(fmt.Sprintf("id: %s\ndata: %s\n\n", filepath.Base(p), data.String()))
+			sent = true
+		}
+		initial = false
+		if sent {
+			idle = 0
+			flush()
+		} else {
+			idle++
+			if idle >= keepAlivePolls {
+				idle = 0
+				writer.addString(keepAlive)
+				flush()
+			}
+		}
+		select {
+		case <-done:
+			return true
+		case <-ticker.C:
+		}
+	}
+}
+
+func streamRequest(ctx *Context, conf *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	d := NewDataWriter(w, func() {
+		w.Header().Set("Cache-Control", "no-cache")
+		writeContent(w, eventContent)
+	})
+	if !HandleStream(ctx, r.URL.Query(), r.Header.Get(lastEventHeader), conf, d, flusher.Flush, r.Context().Done()) {
+		writeErrors(w, d)
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	activeKey   = "active"
+	idleKey     = "idle"
+	orderKey    = "order"
+	descOrder   = "desc"
+	defaultIdle = 10 * time.Minute
+)
+
+var (
+	playerPath  = rawPath("playerid")
+	typePath    = rawPath("type")
+	missionPath = rawPath("mission")
+	worldPath   = rawPath("world")
+	replayPath  = internal.FieldKey + fieldNamespace + "replay"
+)
+
+type (
+	// tagInfo is what is known about a tag (a run)
+	tagInfo struct {
+		Tag      string         `json:"tag"`
+		Start    int64          `json:"start"`
+		StartStr string         `json:"startdt"`
+		End      int64          `json:"end"`
+		EndStr   string         `json:"enddt"`
+		Duration int64          `json:"duration"`
+		Count    int            `json:"count"`
+		Players  int            `json:"players"`
+		Events   map[string]int `json:"events"`
+		World    string         `json:"world,omitempty"`
+		Mission  string         `json:"mission,omitempty"`
+		Active   bool           `json:"active"`
+		players  map[string]struct{}
+	}
+
+	// TagAdder handles tagged results
+	TagAdder struct {
+		objectAdder
+		tags    map[string]*tagInfo
+		active  *bool
+		idle    time.Duration
+		desc    bool
+		invalid []*problem
+	}
+)
+
+func rawPath(name string) string {
+	return internal.FieldKey + fieldNamespace + name + fieldNamespace + internal.NotJSON
+}
+
+// NewTagAdder creates a tag adder from request parameters
+func NewTagAdder(req map[string][]string) *TagAdder {
+	t := &TagAdder{}
+	t.active, t.idle, t.invalid = parseActivity(req)
+	if o, ok := req[orderKey]; ok && len(o) > 0 {
+		switch strings.TrimSpace(o[0]) {
+		case descOrder:
+			t.desc = true
+		case "asc":
+		default:
+			t.invalid = append(t.invalid, newProblem(orderKey, o[0], fmt.Errorf("order must be asc or desc")))
+		}
+	}
+	return t
+}
+
+// parseActivity gets the active filter and how long without records is considered inactive
+func parseActivity(req map[string][]string) (*bool, time.Duration, []*problem) {
+	var active *bool
+	idle := defaultIdle
+	invalid := []*problem{}
+	if a, ok := req[activeKey]; ok && len(a) > 0 {
+		b, err := strconv.ParseBool(strings.TrimSpace(a[0]))
+		if err != nil {
+			invalid = append(invalid, newProblem(activeKey, a[0], err))
+		} else {
+			active = &b
+		}
+	}
+	if i, ok := req[idleKey]; ok && len(i) > 0 {
+		d, err := parseSpan(i[0])
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("idle must be positive")
+		}
+		if err != nil {
+			invalid = append(invalid, newProblem(idleKey, i[0], err))
+		} else {
+			idle = d
+		}
+	}
+	return active, idle, invalid
+}
+
+// activeSince gets the ts after which records indicate activity
+func activeSince(idle time.Duration) int64 {
+	return time.Now().Add(-idle).UnixNano() / int64(time.Millisecond)
+}
+
+func (t *TagAdder) params() []string {
+	return []string{activeKey, idleKey, orderKey}
+}
+
+func (t *TagAdder) problems() []*problem {
+	return t.invalid
+}
+
+func rawString(j map[string]json.RawMessage, path string) (string, bool) {
+	v, ok := fieldValue(j, path)
+	if !ok {
+		return "", false
+	}
+	return internal.JSONstring(v)
+}
+
+func (t *TagAdder) add(first bool, j map[string]json.RawMessage) {
+	if first || t.tags == nil {
+		t.tags = make(map[string]*tagInfo)
+	}
+	_, replay := fieldValue(j, replayPath)
+	tagged := tagPath
+	if replay {
+		// replays indicate the tag as the mission
+		tagged = missionPath
+	}
+	tag, ok := rawString(j, tagged)
+	if !ok {
+		return
+	}
+	ts, ok := internal.JSONint64(j[internal.TSKey])
+	if !ok {
+		return
+	}
+	dt, ok := internal.JSONstring(j[internal.DTKey])
+	if !ok {
+		return
+	}
+	cur, ok := t.tags[tag]
+	if !ok {
+		cur = &tagInfo{Tag: tag, Start: ts, StartStr: dt, End: ts, EndStr: dt}
+		cur.Events = make(map[string]int)
+		cur.players = make(map[string]struct{})
+		t.tags[tag] = cur
+	}
+	cur.Count++
+	if ts < cur.Start {
+		cur.Start = ts
+		cur.StartStr = dt
+	}
+	if ts >= cur.End {
+		cur.End = ts
+		cur.EndStr = dt
+	}
+	if replay {
+		cur.Mission = tag
+		if w, ok := rawString(j, worldPath); ok {
+			cur.World = w
+		}
+		return
+	}
+	if p, ok := rawString(j, playerPath); ok {
+		cur.players[p] = struct{}{}
+	}
+	if e, ok := rawString(j, typePath); ok {
+		cur.Events[e]++
+	}
+}
+
+func (t *TagAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	since := activeSince(t.idle)
+	results := []*tagInfo{}
+	for _, v := range t.tags {
+		v.Duration = v.End - v.Start
+		v.Players = len(v.players)
+		v.Active = v.End >= since
+		if t.active != nil && *t.active != v.Active {
+			continue
+		}
+		results = append(results, v)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		x := results[i]
+		y := results[j]
+		if x.Start == y.Start {
+			return x.Tag < y.Tag
+		}
+		if t.desc {
+			return x.Start > y.Start
+		}
+		return x.Start < y.Start
+	})
+	w.Write(ctx.byteHeader)
+	for i, r := range results {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			internal.Errored("unable to marshal tag", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	gapKey     = "gap"
+	defaultGap = 30 * time.Second
+
+	// URL endpoints
+	timelineURL = "/timeline"
+)
+
+type (
+	// timelineEvent is an event placed in (simulation) time
+	timelineEvent struct {
+		ID      string          `json:"id"`
+		TS      int64           `json:"ts"`
+		DT      string          `json:"dt"`
+		Simtime *float64        `json:"simtime"`
+		Offset  *float64        `json:"offset"`
+		Gap     *float64        `json:"gap,omitempty"`
+		Fields  json.RawMessage `json:"fields"`
+	}
+
+	// TimelineAdder orders the events of a tag by simtime
+	TimelineAdder struct {
+		objectAdder
+		tag     string
+		gap     time.Duration
+		start   *int64
+		events  []*timelineEvent
+		invalid []*problem
+	}
+)
+
+// NewTimelineAdder creates a timeline adder for a tag from request parameters
+func NewTimelineAdder(req map[string][]string, tag string) *TimelineAdder {
+	t := &TimelineAdder{tag: tag, gap: defaultGap}
+	if g, ok := req[gapKey]; ok && len(g) > 0 {
+		d, err := parseSpan(g[0])
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("gap must be positive")
+		}
+		if err != nil {
+			t.invalid = append(t.invalid, newProblem(gapKey, g[0], err))
+		} else {
+			t.gap = d
+		}
+	}
+	return t
+}
+
+func (t *TimelineAdder) params() []string {
+	return []string{gapKey}
+}
+
+func (t *TimelineAdder) problems() []*problem {
+	return t.invalid
+}
+
+func (t *TimelineAdder) found() bool {
+	return len(t.events) > 0
+}
+
+func (t *TimelineAdder) add(first bool, j map[string]json.RawMessage) {
+	if first {
+		t.events = nil
+		t.start = nil
+	}
+	_, isStart := fieldValue(j, startPath)
+	if _, ok := fieldValue(j, eventPath); !ok && !isStart {
+		return
+	}
+	if tag, ok := rawString(j, tagPath); !ok || tag != t.tag {
+		return
+	}
+	m, ok := newSessionMessage(j)
+	if !ok {
+		return
+	}
+	if isStart {
+		if t.start == nil || m.TS < *t.start {
+			t.start = &m.TS
+		}
+		return
+	}
+	e := &timelineEvent{ID: m.ID, TS: m.TS, DT: m.DT, Fields: m.Fields}
+	if f, ok := simtime(j); ok {
+		e.Simtime = &f
+	}
+	t.events = append(t.events, e)
+}
+
+// order sorts by simtime (events without one last, by ts) and adds offsets (from the start message, or the first event without one) and gaps
+func (t *TimelineAdder) order() {
+	sort.SliceStable(t.events, func(i, j int) bool {
+		x := t.events[i]
+		y := t.events[j]
+		if x.Simtime == nil || y.Simtime == nil {
+			if x.Simtime == nil && y.Simtime == nil {
+				return x.TS < y.TS
+			}
+			return y.Simtime == nil
+		}
+		if *x.Simtime == *y.Simtime {
+			return x.TS < y.TS
+		}
+		return *x.Simtime < *y.Simtime
+	})
+	var start, prev *float64
+	for _, e := range t.events {
+		if e.Simtime == nil {
+			continue
+		}
+		if start == nil {
+			// the start message has no simtime, it is placed by how long (ts) before the first event it was
+			s := *e.Simtime
+			if t.start != nil {
+				s -= float64(e.TS-*t.start) / float64(time.Second/time.Millisecond)
+			}
+			start = &s
+		}
+		offset := *e.Simtime - *start
+		e.Offset = &offset
+		if prev != nil {
+			gap := *e.Simtime - *prev
+			if gap > t.gap.Seconds() {
+				e.Gap = &gap
+			}
+		}
+		prev = e.Simtime
+	}
+}
+
+func (t *TimelineAdder) done(ctx *Context, w io.Writer, page *pageMeta) {
+	t.order()
+	w.Write(ctx.byteHeader)
+	for i, e := range t.events {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			internal.Errored("unable to marshal timeline event", err)
+			b = []byte(nullJSON)
+		}
+		w.Write(b)
+	}
+	w.Write([]byte(page.footer()))
+}
+
+func timelineRequest(ctx *Context, conf *internal.Configuration, w http.ResponseWriter, r *http.Request) {
+	tag := pathID(r, timelineURL)
+	if len(tag) == 0 {
+		writeErrors(w, &DataWriter{errors: []*problem{{Message: "no tag given"}}})
+		return
+	}
+	detailRequest(ctx, conf, w, r, NewTimelineAdder(r.URL.Query(), tag), tag)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tzKey        = "tz"
+	nowTime      = "now"
+	dateTimeForm = "2006-01-02T15:04:05"
+)
+
+// parseZone gets the time zone used for any times without one
+func parseZone(value string) (*time.Location, error) {
+	v := strings.TrimSpace(value)
+	if len(v) == 0 {
+		return time.Local, nil
+	}
+	return time.LoadLocation(v)
+}
+
+// parseTime parses epoch milliseconds, RFC3339, dates/times (in the zone), or relative times (e.g. -2h, now-1d)
+func parseTime(value string, loc *time.Location, now time.Time) (time.Time, error) {
+	v := strings.TrimSpace(value)
+	if len(v) == 0 {
+		return time.Time{}, fmt.Errorf("no time given")
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(0, i*int64(time.Millisecond)).In(loc), nil
+	}
+	relative := strings.TrimPrefix(v, nowTime)
+	if len(relative) == 0 {
+		return now, nil
+	}
+	if strings.HasPrefix(relative, "-") || strings.HasPrefix(relative, "+") {
+		d, err := parseSpan(relative)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time: %s", value)
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	for _, f := range []string{dateTimeForm, dayFormat} {
+		if t, err := time.ParseInLocation(f, v, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", value)
+}
+
+// epochMillis gets the time as a ts value
+func epochMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
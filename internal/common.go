@@ -20,6 +20,10 @@ const (
 	DumpKey = "dump"
 	// DTKey is the datetime key
 	DTKey = "dt"
+	// VersKey is the (armq) version key
+	VersKey = "vers"
+	// ValueKey is a typed field value
+	ValueKey = "value"
 	// NotJSON indicates raw json-ish object
 	NotJSON = "raw"
 	// FieldKey is for the fields in the data
@@ -63,9 +67,36 @@ func Startup(vers string) (*Configuration, []string) {
 	if err := yaml.Unmarshal(b, c); err != nil {
 		Fatal("unable to parse config %v", err)
 	}
+	c.upgrade()
 	return c, flag.Args()
 }
 
+// upgrade maps deprecated (boolean) handler settings onto the handler messages
+func (c *Configuration) upgrade() {
+	h := &c.API.Handlers
+	deprecated := []struct {
+		name string
+		on   bool
+	}{{"event", h.Event}, {"start", h.Start}, {"replay", h.Replay}, {"player", h.Player}}
+	for _, d := range deprecated {
+		if !d.on {
+			continue
+		}
+		name := d.name
+		Info(fmt.Sprintf("handlers.%s is deprecated, add it to handlers.messages", name))
+		found := false
+		for _, m := range h.Messages {
+			if m == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Messages = append(h.Messages, name)
+		}
+	}
+}
+
 type (
 	// TypeConv is an indicator ot type conversion
 	TypeConv int
@@ -82,7 +113,9 @@ type (
 		Array []json.RawMessage `json:"array,omitempty"`
 		// Represents a map (object)
 		Object map[string]json.RawMessage `json:"object,omitempty"`
-		Name   string                     `json:"-"`
+		// Represents a typed (number, bool, time) value
+		Value json.RawMessage `json:"value,omitempty"`
+		Name  string          `json:"-"`
 	}
 
 	// Configuration for the server
@@ -92,6 +125,7 @@ type (
 			Workers int
 			Output  string
 			Dump    bool
+			Zone    string
 		}
 		Files struct {
 			Directory string
@@ -107,13 +141,22 @@ type (
 			EndScan   int
 			Extract   string
 			SpinUp    int
+			Poll      int
+			Follow    int
+			Lenient   bool
 			Service   bool
 			NoHost    bool
-			Handlers  struct {
+			Schemas   string
+			// filter/sort types by field path (int64, string, int, float, bool)
+			Converters map[string]string
+			Handlers   struct {
 				Enable bool
 				Dump   bool
-				Event  bool
 				Empty  bool
+				// handlers (by name) to use for messages
+				Messages []string
+				// deprecated: these are added to the handler messages
+				Event  bool
 				Start  bool
 				Replay bool
 				Player bool
@@ -127,9 +170,49 @@ func Now() string {
 	return time.Now().Format("2006-01-02T15-04-05")
 }
 
+// Plain gets the entry as plain JSON (without the jsontype wrapping)
+func (e *Entry) Plain() json.RawMessage {
+	var v interface{}
+	switch {
+	case len(e.Value) > 0:
+		return e.Value
+	case e.Type == ArrayJSON:
+		v = e.Array
+	case e.Type == ObjJSON:
+		v = e.Object
+	case e.Type == NotJSON:
+		v = e.Raw
+	}
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		Errored("unable to marshal entry", err)
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// DayFormat is the (output) day directory name format
+const DayFormat = "2006-01-02"
+
+// DayZone gets the time zone that day directories follow (defaults to local)
+func (c *Configuration) DayZone() *time.Location {
+	if c.Global.Zone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Global.Zone)
+	if err != nil {
+		Errored("unable to load zone, using local", err)
+		return time.Local
+	}
+	return loc
+}
+
 // HandleFields indicates if the handlers support field handling
 func (c *Configuration) HandleFields() bool {
-	return c.API.Handlers.Event || c.API.Handlers.Start || c.API.Handlers.Player || c.API.Handlers.Replay
+	return len(c.API.Handlers.Messages) > 0
 }
 
 // Info is for informational messages
@@ -7,22 +7,12 @@ import (
 )
 
 const (
-	eventType    = "event"
-	startType    = "start"
-	replayType   = "replay"
-	playerType   = "player"
-	playerIDType = "playerid"
-	emptyJSON    = "empty"
-	field0Key    = internal.FKey + "0"
-	field1Key    = internal.FKey + "1"
-	field2Key    = internal.FKey + "2"
-	field3Key    = internal.FKey + "3"
-	field4Key    = internal.FKey + "4"
-	field5Key    = internal.FKey + "5"
+	emptyJSON = "empty"
+	field0Key = internal.FKey + "0"
 )
 
 func isEmpty(e *internal.Entry) bool {
-	return len(e.Raw) == 0 && len(e.Array) == 0 && len(e.Object) == 0
+	return len(e.Raw) == 0 && len(e.Array) == 0 && len(e.Object) == 0 && len(e.Value) == 0
 }
 
 func isRaw(e *internal.Entry) bool {
@@ -58,32 +48,17 @@ func handleAll(entries map[string]*internal.Entry, h entityHandler) map[string]*
 	return h.handle(len(entries), entries)
 }
 
-// HandleEntries is responsible for taking a set of input entries and massaging them into useful data
-func HandleEntries(entries map[string]*internal.Entry, settings *internal.Configuration) map[string]*internal.Entry {
+// HandleEntries is responsible for taking a set of input entries (of a version) and massaging them into useful data
+func HandleEntries(entries map[string]*internal.Entry, version string, settings *internal.Configuration) map[string]*internal.Entry {
 	if len(entries) == 0 {
 		return entries
 	}
 	var handler entityHandler
 	handler = &defaultHandler{}
 	first, ok := entries[field0Key]
-	if ok {
-		firstField := ""
-		if settings.HandleFields() {
-			if isRaw(first) {
-				firstField = first.Raw
-			}
-		}
-		if settings.API.Handlers.Event && firstField == eventType {
-			handler = &eventHandler{}
-		}
-		if settings.API.Handlers.Start && firstField == startType {
-			handler = &startHandler{}
-		}
-		if settings.API.Handlers.Replay && firstField == replayType {
-			handler = &replayHandler{}
-		}
-		if settings.API.Handlers.Player && firstField == playerType {
-			handler = &playerHandler{}
+	if ok && settings.HandleFields() && isRaw(first) {
+		if h, ok := handlerFor(settings, first.Raw, version); ok {
+			handler = h
 		}
 	}
 	r := make(map[string]*internal.Entry)
@@ -103,21 +78,6 @@ func HandleEntries(entries map[string]*internal.Entry, settings *internal.Config
 }
 
 type (
-	// indicates a 'start'
-	startHandler struct {
-		entityHandler
-	}
-
-	// indicates a 'player'
-	playerHandler struct {
-		entityHandler
-	}
-
-	// indicates a 'replay'
-	replayHandler struct {
-		entityHandler
-	}
-
 	entityHandler interface {
 		handle(int, map[string]*internal.Entry) map[string]*internal.Entry
 	}
@@ -126,11 +86,6 @@ type (
 		entityHandler
 	}
 
-	// handles anything marked as an event
-	eventHandler struct {
-		entityHandler
-	}
-
 	entityCheck func(e *internal.Entry) bool
 )
 
@@ -154,42 +109,3 @@ func rewriteName(name, field string, check entityCheck, entries map[string]*inte
 func set(e *internal.Entry) bool {
 	return true
 }
-
-func (h *eventHandler) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
-	rewriteName(eventType, field0Key, set, entries)
-	if rewriteName(internal.TagKey, field1Key, isTag, entries) {
-		if rewriteName(playerIDType, field2Key, isRaw, entries) {
-			if rewriteName("type", field3Key, isRaw, entries) {
-				if rewriteName("data", field4Key, isNotRaw, entries) {
-					rewriteName("simtime", field5Key, isRaw, entries)
-				}
-			}
-		}
-	}
-	return entries
-}
-
-func (h *startHandler) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
-	rewriteName(startType, field0Key, set, entries)
-	return entries
-}
-
-func (h *playerHandler) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
-	rewriteName(playerType, field0Key, set, entries)
-	if rewriteName(playerIDType, field1Key, isRaw, entries) {
-		rewriteName("name", field2Key, isRaw, entries)
-	}
-	return entries
-}
-
-func (h *replayHandler) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
-	rewriteName(replayType, field0Key, set, entries)
-	if rewriteName("mission", field1Key, isTag, entries) {
-		if rewriteName("world", field2Key, isRaw, entries) {
-			if rewriteName("daytime", field3Key, isRaw, entries) {
-				rewriteName("version", field4Key, isNotRaw, entries)
-			}
-		}
-	}
-	return entries
-}
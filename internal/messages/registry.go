@@ -0,0 +1,92 @@
+package messages
+
+import (
+	"fmt"
+	"sync"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+var (
+	registryLock = &sync.RWMutex{}
+	registry     = make(map[string][]*registration)
+)
+
+type (
+	// Handler names (or otherwise adjusts) the entries of a message
+	Handler interface {
+		Handle(entries map[string]*internal.Entry) map[string]*internal.Entry
+	}
+
+	// HandlerFunc is a function used as a handler
+	HandlerFunc func(entries map[string]*internal.Entry) map[string]*internal.Entry
+
+	// registration is a handler for a message type (the value of field0)
+	registration struct {
+		entityHandler
+		versions
+		name    string
+		handler Handler
+	}
+)
+
+// Handle calls the handler function
+func (f HandlerFunc) Handle(entries map[string]*internal.Entry) map[string]*internal.Entry {
+	return f(entries)
+}
+
+func (r *registration) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
+	return r.handler.Handle(entries)
+}
+
+// Register adds a handler (enabled by name in the configured handler messages) for a message type
+func Register(name, messageType string, h Handler) {
+	RegisterVersions(name, messageType, "", "", h)
+}
+
+// RegisterVersions adds a handler for a message type from the since version (inclusive) and before a version (exclusive)
+func RegisterVersions(name, messageType, since, before string, h Handler) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if len(name) == 0 || len(messageType) == 0 || h == nil {
+		panic("invalid message handler registration")
+	}
+	r := &registration{name: name, handler: h, versions: versions{Since: since, Before: before}}
+	if err := r.valid(); err != nil {
+		panic(fmt.Sprintf("invalid message handler versions: %v", err))
+	}
+	for _, e := range registry[messageType] {
+		if e.name == name && e.versions == r.versions {
+			panic(fmt.Sprintf("message handler already registered: %s (%s)", messageType, name))
+		}
+	}
+	registry[messageType] = append(registry[messageType], r)
+}
+
+// enabled indicates if a handler is configured
+func enabled(settings *internal.Configuration, name string) bool {
+	for _, m := range settings.API.Handlers.Messages {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handlerFor gets the (enabled) handler of a message type for a version, a registered handler is used before a schema
+func handlerFor(settings *internal.Configuration, messageType, version string) (entityHandler, bool) {
+	registryLock.RLock()
+	registered := registry[messageType]
+	registryLock.RUnlock()
+	for _, r := range registered {
+		if enabled(settings, r.name) && r.matches(version) {
+			return r, true
+		}
+	}
+	for _, m := range schemas(settings)[messageType] {
+		if enabled(settings, m.name()) && m.matches(version) {
+			return m, true
+		}
+	}
+	return nil, false
+}
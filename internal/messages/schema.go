@@ -0,0 +1,159 @@
+package messages
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	rawEntry    = "raw"
+	arrayEntry  = "array"
+	objectEntry = "object"
+	notRawEntry = "notraw"
+	tagEntry    = "tag"
+	anyEntry    = "any"
+)
+
+var (
+	schemaLock  = &sync.Mutex{}
+	schemaCache = make(map[string]map[string][]*messageSchema)
+
+	entryChecks = map[string]entityCheck{
+		rawEntry:    isRaw,
+		arrayEntry:  isArray,
+		objectEntry: isObject,
+		notRawEntry: isNotRaw,
+		tagEntry:    isTag,
+		anyEntry:    set,
+	}
+)
+
+type (
+	schemaField struct {
+		Name     string
+		Type     string
+		Optional bool
+		// target type (float, int, bool, time) of a raw field
+		As    string
+		check entityCheck
+	}
+
+	// messageSchema names the fields of a message (by the value of field0)
+	messageSchema struct {
+		Type string
+		// handler name (as configured), defaults to the type
+		Name     string
+		versions `yaml:",inline"`
+		Fields   []*schemaField
+	}
+
+	schemaFile struct {
+		Messages []*messageSchema
+	}
+)
+
+// parseSchemas reads message schemas (by type)
+func parseSchemas(b []byte) (map[string][]*messageSchema, error) {
+	file := &schemaFile{}
+	if err := yaml.Unmarshal(b, file); err != nil {
+		return nil, err
+	}
+	schemas := make(map[string][]*messageSchema)
+	for _, m := range file.Messages {
+		if len(m.Type) == 0 {
+			return nil, fmt.Errorf("message schema has no type")
+		}
+		if err := m.valid(); err != nil {
+			return nil, fmt.Errorf("message schema %s: %v", m.Type, err)
+		}
+		for _, f := range m.Fields {
+			if len(f.Name) == 0 {
+				return nil, fmt.Errorf("unnamed field in message schema: %s", m.Type)
+			}
+			t := f.Type
+			if len(t) == 0 {
+				t = anyEntry
+			}
+			check, ok := entryChecks[t]
+			if !ok {
+				return nil, fmt.Errorf("unknown entry type in message schema %s: %s", m.Type, t)
+			}
+			f.check = check
+			if len(f.As) > 0 && !isTargetType(f.As) {
+				return nil, fmt.Errorf("unknown target type in message schema %s: %s", m.Type, f.As)
+			}
+		}
+		schemas[m.Type] = append(schemas[m.Type], m)
+	}
+	return schemas, nil
+}
+
+// LoadSchemas reads message schemas from a file (the built-in schemas are used when no file is given)
+func LoadSchemas(path string) error {
+	_, err := loadSchemas(path)
+	return err
+}
+
+func loadSchemas(path string) (map[string][]*messageSchema, error) {
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	if s, ok := schemaCache[path]; ok {
+		return s, nil
+	}
+	b := []byte(defaultSchemas)
+	if len(path) > 0 {
+		r, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		b = r
+	}
+	s, err := parseSchemas(b)
+	if err != nil {
+		return nil, err
+	}
+	schemaCache[path] = s
+	return s, nil
+}
+
+// schemas gets the configured message schemas (falling back to the built-in schemas)
+func schemas(settings *internal.Configuration) map[string][]*messageSchema {
+	s, err := loadSchemas(settings.API.Schemas)
+	if err != nil {
+		internal.Errored("unable to load message schemas", err)
+		s, _ = loadSchemas("")
+		schemaLock.Lock()
+		schemaCache[settings.API.Schemas] = s
+		schemaLock.Unlock()
+	}
+	return s
+}
+
+func (m *messageSchema) name() string {
+	if len(m.Name) == 0 {
+		return m.Type
+	}
+	return m.Name
+}
+
+// handle names the fields (after field0) in order, an unmatched required field stops any further naming
+func (m *messageSchema) handle(count int, entries map[string]*internal.Entry) map[string]*internal.Entry {
+	rewriteName(m.Type, field0Key, set, entries)
+	for i, f := range m.Fields {
+		key := fmt.Sprintf("%s%d", internal.FKey, i+1)
+		if !rewriteName(f.Name, key, f.check, entries) {
+			if f.Optional {
+				continue
+			}
+			break
+		}
+		if len(f.As) > 0 {
+			Coerce(entries[key], f.As)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,119 @@
+package messages
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidedtech.com/armq-server/internal"
+)
+
+const (
+	// FloatType is a raw field declared as a float
+	FloatType = "float"
+	// IntType is a raw field declared as an integer
+	IntType = "int"
+	// BoolType is a raw field declared as a bool
+	BoolType = "bool"
+	// TimeType is a raw field declared as a time
+	TimeType = "time"
+	// TimeFormat is the format of time values, they are fixed width (UTC) so they can be compared as strings
+	TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+	numberJSON = "number"
+	boolJSON   = "bool"
+	timeJSON   = "time"
+)
+
+var (
+	targetTypes = map[string]string{
+		FloatType: numberJSON,
+		IntType:   numberJSON,
+		BoolType:  boolJSON,
+		TimeType:  timeJSON,
+	}
+)
+
+func isTargetType(as string) bool {
+	_, ok := targetTypes[as]
+	return ok
+}
+
+// parseTime reads RFC3339 or epoch seconds
+func parseTime(raw string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, true
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true
+}
+
+// Coerce converts a raw entry to a typed value (e.g. {"jsontype": "number", "value": 1.5}), the entry is unchanged when it can not be
+func Coerce(e *internal.Entry, as string) bool {
+	if !isRaw(e) {
+		return false
+	}
+	raw := strings.TrimSpace(e.Raw)
+	var v interface{}
+	switch as {
+	case FloatType:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return false
+		}
+		v = f
+	case IntType:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return false
+		}
+		v = i
+	case BoolType:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false
+		}
+		v = b
+	case TimeType:
+		t, ok := parseTime(raw)
+		if !ok {
+			return false
+		}
+		v = t.UTC().Format(TimeFormat)
+	default:
+		return false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	e.Type = targetTypes[as]
+	e.Value = b
+	e.Raw = ""
+	return true
+}
+
+// Types gets the declared (target) types of (enabled) schema fields by name, the first declaration of a name is used
+func Types(settings *internal.Configuration) map[string]string {
+	types := make(map[string]string)
+	for _, set := range schemas(settings) {
+		for _, m := range set {
+			if !enabled(settings, m.name()) {
+				continue
+			}
+			for _, f := range m.Fields {
+				if len(f.As) == 0 {
+					continue
+				}
+				if _, ok := types[f.Name]; !ok {
+					types[f.Name] = f.As
+				}
+			}
+		}
+	}
+	return types
+}
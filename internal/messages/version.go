@@ -0,0 +1,79 @@
+package messages
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// versions is a range of (armq) versions, since (inclusive) and before (exclusive) are optional
+	versions struct {
+		Since  string
+		Before string
+	}
+)
+
+// compareVersions compares dotted versions numerically (missing parts are 0)
+func compareVersions(a, b string) int {
+	x := strings.Split(strings.TrimSpace(a), ".")
+	y := strings.Split(strings.TrimSpace(b), ".")
+	for len(x) < len(y) {
+		x = append(x, "0")
+	}
+	for len(y) < len(x) {
+		y = append(y, "0")
+	}
+	for i := range x {
+		l, lerr := strconv.Atoi(x[i])
+		r, rerr := strconv.Atoi(y[i])
+		if lerr != nil || rerr != nil {
+			if c := strings.Compare(x[i], y[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if l != r {
+			if l < r {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func validVersion(v string) error {
+	for _, p := range strings.Split(v, ".") {
+		if _, err := strconv.Atoi(p); err != nil {
+			return fmt.Errorf("invalid version: %s", v)
+		}
+	}
+	return nil
+}
+
+func (v versions) valid() error {
+	for _, b := range []string{v.Since, v.Before} {
+		if len(b) == 0 {
+			continue
+		}
+		if err := validVersion(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches indicates if a version is in the range (records without a version match any range)
+func (v versions) matches(version string) bool {
+	if len(version) == 0 {
+		return true
+	}
+	if len(v.Since) > 0 && compareVersions(version, v.Since) < 0 {
+		return false
+	}
+	if len(v.Before) > 0 && compareVersions(version, v.Before) >= 0 {
+		return false
+	}
+	return true
+}
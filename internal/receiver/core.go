@@ -139,7 +139,7 @@ func writerWorker(id, count int, outdir string, obj *object, conf *internal.Conf
 }
 
 func resetWorker(conf *internal.Configuration) (int, string) {
-	now := time.Now().Format("2006-01-02")
+	now := time.Now().In(conf.DayZone()).Format(internal.DayFormat)
 	p := filepath.Join(conf.Global.Output, now)
 	if !internal.PathExists(p) {
 		if err := os.MkdirAll(p, 0755); err != nil {
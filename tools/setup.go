@@ -18,8 +18,17 @@ import (
 )
 
 const quoteByte = byte('"')
+`
+	schemaSource = "../configs/messages.yaml"
+	schemaOutput = "../internal/messages/generated.go"
+	schemaBody   = `// Code generated by setup.go. DO NOT EDIT.
+package messages
+
+// defaultSchemas are the built-in message schemas (from configs/messages.yaml)
+const defaultSchemas = %q
 `
 	strType  = "string"
+	boolType = "bool"
 	convBody = `
 func JSON{{.Name}}Converter(expect {{.Name}}, d []byte, op OpType) bool {
 	i, ok := JSON{{.Name}}(d)
@@ -80,13 +89,17 @@ func genType(idx int, t string, b *bytes.Buffer) {
 		def = "\"\""
 		isNumeric = false
 	}
+	if t == boolType {
+		def = "false"
+		isNumeric = false
+	}
 	obj := &Object{Name: t, Value: def, IsNum: isNumeric}
 	runTemplate(convBody, b, obj)
 }
 
 func converters() {
 	var b *bytes.Buffer
-	for i, t := range []string{"int", "int64", strType, "float64"} {
+	for i, t := range []string{"int", "int64", strType, "float64", boolType} {
 		b = genFile(i, t, b, genType)
 	}
 	write(b)
@@ -106,8 +119,20 @@ func runTemplate(text string, b *bytes.Buffer, obj *Object) {
 	}
 }
 
+func schemas() {
+	b, err := ioutil.ReadFile(schemaSource)
+	if err != nil {
+		fail("schema source", err)
+	}
+	writeTo(schemaOutput, bytes.NewBufferString(fmt.Sprintf(schemaBody, b)))
+}
+
 func write(b *bytes.Buffer) {
-	if err := ioutil.WriteFile("../internal/generated.go", b.Bytes(), 0644); err != nil {
+	writeTo("../internal/generated.go", b)
+}
+
+func writeTo(path string, b *bytes.Buffer) {
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
 		fail("output file", err)
 	}
 }
@@ -124,6 +149,7 @@ func genFile(idx int, obj string, b *bytes.Buffer, callback genCall) *bytes.Buff
 
 func gen() {
 	converters()
+	schemas()
 }
 
 func main() {
@@ -3,11 +3,17 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"voidedtech.com/armq-server/internal"
 	"voidedtech.com/armq-server/internal/api"
+	"voidedtech.com/armq-server/internal/messages"
 )
 
 func testHandlers() *internal.Configuration {
@@ -15,10 +21,20 @@ func testHandlers() *internal.Configuration {
 	cfg.API.Handlers.Enable = true
 	cfg.API.Handlers.Dump = true
 	cfg.API.Handlers.Empty = true
-	cfg.API.Handlers.Event = true
+	cfg.API.Handlers.Messages = []string{"event"}
 	return cfg
 }
 
+const (
+	outputDir = "bin/"
+)
+
+var (
+	// cursors include the (test run) day
+	cursors = regexp.MustCompile(`"cursor": "[^"]+"`)
+	elapsed = regexp.MustCompile(`"elapsed": [0-9]+`)
+)
+
 type (
 	writerAdjust func(*api.DataWriter)
 
@@ -29,6 +45,7 @@ type (
 		hdl *internal.Configuration
 		ok  bool
 		adj writerAdjust
+		raw bool
 	}
 )
 
@@ -36,15 +53,130 @@ func runTest(c *api.Context, output string, r map[string][]string, h *internal.C
 	test(&testHarness{ctx: c, out: output, req: r, hdl: h, ok: success})
 }
 
-func tagTest(c *api.Context) {
-	h := &testHarness{ctx: c, out: "tags", req: nil, hdl: nil, ok: true}
+func tagTest(c *api.Context, name string, r map[string][]string, h *internal.Configuration) {
+	t := &testHarness{ctx: c, out: name, req: r, hdl: h, ok: true}
+	t.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewTagAdder(r))
+	}
+	test(t)
+}
+
+func sessionTest(c *api.Context, name, tag string) {
+	t := &testHarness{ctx: c, out: name, req: nil, hdl: missionHandlers(), ok: true}
+	t.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewSessionAdder(nil, tag))
+	}
+	test(t)
+}
+
+func playerTest(c *api.Context, name, id string) {
+	t := &testHarness{ctx: c, out: name, req: nil, hdl: missionHandlers(), ok: true}
+	t.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewPlayerAdder(id))
+	}
+	test(t)
+}
+
+func timelineTest(c *api.Context, name, tag string) {
+	t := &testHarness{ctx: c, out: name, req: nil, hdl: missionHandlers(), ok: true}
+	t.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewTimelineAdder(nil, tag))
+	}
+	test(t)
+}
+
+func recordTest(c *api.Context, name, id string, r map[string][]string, found bool) {
+	var b bytes.Buffer
+	d := api.NewDataWriter(&b, nil)
+	ok, valid := api.HandleRecord(c, id, r, missionHandlers(), d)
+	if ok != found {
+		panic("failed test: " + name)
+	}
+	if !ok || !valid {
+		d.WriteErrors(&b)
+	}
+	writeResult(b.Bytes(), name)
+}
+
+func nextCursor(output []byte) string {
+	page := struct {
+		Page struct {
+			Cursor string
+		}
+	}{}
+	if err := json.Unmarshal(output, &page); err != nil {
+		panic("unable to read cursor")
+	}
+	return page.Page.Cursor
+}
+
+func cursorTest(c *api.Context, name string, r map[string][]string) {
+	m := make(map[string][]string)
+	for k, v := range r {
+		m[k] = v
+	}
+	m["limit"] = []string{"2"}
+	m["fields"] = []string{"id"}
+	pagedTest(c, name, m)
+}
+
+func followTest(c *api.Context, name string) {
+	m := make(map[string][]string)
+	m["follow"] = []string{"true"}
+	m["fields"] = []string{"id"}
+	pagedTest(c, name, m)
+}
+
+func pagedTest(c *api.Context, name string, m map[string][]string) {
+	cursor := nextCursor(test(&testHarness{ctx: c, out: name + "1", req: m, hdl: missionHandlers(), ok: true}))
+	if cursor == "" {
+		panic("no cursor: " + name)
+	}
+	m["cursor"] = []string{cursor}
+	test(&testHarness{ctx: c, out: name + "2", req: m, hdl: missionHandlers(), ok: true})
+}
+
+func aggregateTest(c *api.Context, name string, r map[string][]string, success bool) {
+	h := &testHarness{ctx: c, out: name, req: r, hdl: missionHandlers(), ok: success}
+	h.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewAggregateAdder(r))
+	}
+	test(h)
+}
+
+func distinctTest(c *api.Context, name string, r map[string][]string) {
+	h := &testHarness{ctx: c, out: name, req: r, hdl: missionHandlers(), ok: true}
+	h.adj = func(d *api.DataWriter) {
+		d.ObjectWriter(api.NewDistinctAdder(r))
+	}
+	test(h)
+}
+
+func formatTest(c *api.Context, name string, r map[string][]string) {
+	test(&testHarness{ctx: c, out: name, req: r, hdl: missionHandlers(), ok: true, raw: true})
+}
+
+// trailerTest checks the paging state given (as trailers) for formats without a page footer
+func trailerTest(c *api.Context, name string, r map[string][]string) {
+	h := &testHarness{ctx: c, out: name, req: r, hdl: missionHandlers(), ok: true, raw: true}
+	var trailers []byte
 	h.adj = func(d *api.DataWriter) {
-		d.ObjectWriter(&api.TagAdder{})
+		d.Trailers(func(cursor string, limited bool) {
+			b, err := json.Marshal(map[string]interface{}{"cursor": cursor, "limited": limited})
+			if err != nil {
+				panic("unable to marshal trailers")
+			}
+			trailers = b
+		})
 	}
 	test(h)
+	if trailers == nil {
+		panic("no trailers: " + name)
+	}
+	writeResult(trailers, name+"trailers")
 }
 
-func test(h *testHarness) {
+func test(h *testHarness) []byte {
 	str := ""
 	b := bytes.NewBufferString(str)
 	request := h.req
@@ -63,22 +195,38 @@ func test(h *testHarness) {
 	if h.adj != nil {
 		h.adj(d)
 	}
-	api.Handle(h.ctx, request, handlers, d)
+	if !api.Handle(h.ctx, request, handlers, d) {
+		d.WriteErrors(b)
+	}
 	if called != h.ok {
 		panic("failed test: " + h.out)
 	}
+	if h.raw {
+		if err := ioutil.WriteFile(outputDir+h.out, b.Bytes(), 0644); err != nil {
+			panic("unable to complete test")
+		}
+		return b.Bytes()
+	}
+	writeResult(b.Bytes(), h.out)
+	return b.Bytes()
+}
+
+// writeResult outputs (indented and with volatile values masked) test results
+func writeResult(b []byte, name string) {
 	var indent bytes.Buffer
-	if err := json.Indent(&indent, b.Bytes(), "", "  "); err != nil {
-		panic("unable to adjust output")
+	if err := json.Indent(&indent, b, "", "  "); err != nil {
+		panic("unable to adjust output: " + name)
 	}
-	if err := ioutil.WriteFile(h.ctx.Directory+h.out, indent.Bytes(), 0644); err != nil {
+	output := cursors.ReplaceAll(indent.Bytes(), []byte(`"cursor": "<cursor>"`))
+	output = elapsed.ReplaceAll(output, []byte(`"elapsed": 0`))
+	if err := ioutil.WriteFile(outputDir+name, output, 0644); err != nil {
 		panic("unable to complete test")
 	}
 }
 
 func main() {
 	c := &api.Context{}
-	c.Directory = "bin/"
+	c.Directory = outputDir
 	c.Limit = 10
 	c.ScanStart = -10 * 24 * time.Hour
 	c.ScanEnd = 24 * time.Hour
@@ -105,8 +253,11 @@ func main() {
 	c.Convert = api.DefaultConverters()
 	delete(m, "start")
 	delete(m, "end")
-	c.Convert["fields.simtime.raw"] = api.Float64Conv
-	filter := []string{"fields.simtime.raw:gt:100"}
+	// simtime is declared as a float (no converter needed)
+	for k, v := range api.SchemaConverters(testHandlers()) {
+		c.Convert[k] = v
+	}
+	filter := []string{"fields.simtime.value:gt:100"}
 	m["filter"] = filter
 	runTest(c, "filters", m, nil, true)
 	filter = append(filter, "id:eq:2018-10-04T12-43-25.1538671495161.2.0")
@@ -114,5 +265,327 @@ func main() {
 	m["filter"] = filter
 	runTest(c, "filtersand", m, nil, true)
 	c.Convert = api.DefaultConverters()
-	tagTest(c)
+	tagTest(c, "tags", nil, nil)
+	missionTests()
+	restartTests()
+	streamTests()
+	followWaitTests()
+	undatedTests()
+	joinedTests()
+}
+
+// players join the session running (or the next one starting) when they joined
+func joinedTests() {
+	c := newMissionContext()
+	c.Directory = "jbin/"
+	sessionTest(c, "sessionsjoined", "")
+	sessionTest(c, "sessionjoined", "wxyz")
+	sessionTest(c, "sessionjoinednext", "vwxy")
+}
+
+// directories not named by day are dated by when they were (last) modified
+func undatedTests() {
+	c := newMissionContext()
+	c.Directory = "sbin/"
+	writeRecord(filepath.Join(c.Directory, "current"), "2018-10-06T10-00-00.1538820000000.0.0", 1538820000000, false)
+	m := map[string][]string{"fields": {"id"}, "sort": {"ts"}, "enddate": {time.Now().Format("2006-01-02")}}
+	runTest(c, "undated", m, missionHandlers(), true)
+}
+
+// followWait follows (after the cursor) while records are written, the elapsed time is checked
+func followWait(c *api.Context, name string, m map[string][]string, writes func(), done <-chan struct{}, check func(time.Duration) bool) []byte {
+	var b bytes.Buffer
+	d := api.NewDataWriter(&b, func() {})
+	if writes != nil {
+		go writes()
+	}
+	started := time.Now()
+	if !api.HandleFollow(c, missionHandlers(), m, d, done) {
+		panic("failed test: " + name)
+	}
+	if !check(time.Since(started)) {
+		panic("unexpected wait: " + name)
+	}
+	return b.Bytes()
+}
+
+// follow waiting (until the timeout, for new records or until the request is done)
+func followWaitTests() {
+	c := newMissionContext()
+	c.Directory = "sbin/"
+	c.Poll = 50 * time.Millisecond
+	dir := filepath.Join(c.Directory, time.Now().Format("2006-01-02"))
+	writeRecord(dir, "2018-10-06T09-00-00.1538816400000.0.0", 1538816400000, false)
+	m := map[string][]string{"fields": {"id"}, "follow": {"true"}}
+	cursor := nextCursor(test(&testHarness{ctx: c, out: "followwait", req: m, hdl: missionHandlers(), ok: true}))
+	if cursor == "" {
+		panic("no cursor: followwait")
+	}
+	m["cursor"] = []string{cursor}
+	c.Follow = 300 * time.Millisecond
+	b := followWait(c, "follownone", m, nil, nil, func(d time.Duration) bool {
+		return d >= c.Follow
+	})
+	writeResult(b, "follownone")
+	c.Follow = 5 * time.Second
+	b = followWait(c, "follownew", m, func() {
+		time.Sleep(100 * time.Millisecond)
+		writeRecord(dir, "2018-10-06T09-00-00.1538816401000.0.1", 1538816401000, false)
+	}, nil, func(d time.Duration) bool {
+		return d < c.Follow
+	})
+	writeResult(b, "follownew")
+	m["cursor"] = []string{nextCursor(b)}
+	done := make(chan struct{})
+	b = followWait(c, "followdone", m, func() {
+		time.Sleep(100 * time.Millisecond)
+		close(done)
+	}, done, func(d time.Duration) bool {
+		return d < c.Follow
+	})
+	if len(b) != 0 {
+		panic("unexpected output: followdone")
+	}
+}
+
+// writeRecord writes a record (or a partially written one) to a day directory
+func writeRecord(dir, name string, ts int64, partial bool) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic("unable to create record dir")
+	}
+	data := fmt.Sprintf(`{"id": "%s", "ts": %d, "vers": "1.1.0", "dump": {}, "fields": {}}`, name, ts)
+	if partial {
+		data = data[0 : len(data)/2]
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+		panic("unable to write record")
+	}
+}
+
+func streamTest(c *api.Context, name, lastID string, wait time.Duration, writes func()) {
+	var b bytes.Buffer
+	d := api.NewDataWriter(&b, func() {})
+	done := make(chan struct{})
+	go func() {
+		if writes != nil {
+			writes()
+		}
+		time.Sleep(wait)
+		close(done)
+	}()
+	if !api.HandleStream(c, make(map[string][]string), lastID, missionHandlers(), d, func() {}, done) {
+		panic("failed test: " + name)
+	}
+	if err := ioutil.WriteFile(outputDir+name, b.Bytes(), 0644); err != nil {
+		panic("unable to complete test")
+	}
+}
+
+// stream watching (resuming, new records and records still being written)
+func streamTests() {
+	c := newMissionContext()
+	c.Directory = "rbin/"
+	// no poll interval configured (default), resumes after the last event before waiting
+	c.Poll = 0
+	streamTest(c, "streamresume", "2018-10-05T20-00-00.1538766001000.0.0", 0, nil)
+	dir := "sbin/2018-10-06"
+	writeRecord(dir, "2018-10-06T08-00-00.1538812800000.0.0", 1538812800000, false)
+	c.Directory = "sbin/"
+	c.Poll = 50 * time.Millisecond
+	streamTest(c, "streamnew", "", 250*time.Millisecond, func() {
+		time.Sleep(125 * time.Millisecond)
+		writeRecord(dir, "2018-10-06T08-00-00.1538812801000.0.1", 1538812801000, true)
+		writeRecord(dir, "2018-10-06T08-00-00.1538812802000.0.2", 1538812802000, false)
+		writeRecord(dir, "2018-10-06T08-00-00.1538812803000.0.3", 1538812803000, true)
+		time.Sleep(50 * time.Millisecond)
+		// finished writing (before running out of retries)
+		writeRecord(dir, "2018-10-06T08-00-00.1538812801000.0.1", 1538812801000, false)
+	})
+}
+
+// worker counts restart (in the same day) after a worker idles
+func restartTests() {
+	c := newMissionContext()
+	c.Directory = "rbin/"
+	m := make(map[string][]string)
+	m["sort"] = []string{"ts"}
+	m["fields"] = []string{"id,ts"}
+	runTest(c, "restart", m, missionHandlers(), true)
+	m["sort"] = []string{"-ts"}
+	runTest(c, "restartdesc", m, missionHandlers(), true)
+	// a busy worker wrote this (days later) to the day it started on
+	recordTest(c, "recordbusy", "2018-10-01T08-00-00.1538766000000.2.9000", map[string][]string{"fields": {"id", "ts"}}, true)
+}
+
+// newMissionContext builds the context as the api does (from configuration)
+func newMissionContext() *api.Context {
+	return newConfiguredContext(missionHandlers())
+}
+
+func newConfiguredContext(cfg *internal.Configuration) *api.Context {
+	cfg.Global.Output = "mbin/"
+	cfg.API.Limit = 10
+	cfg.API.StartScan = -10
+	cfg.API.EndScan = 1
+	cfg.API.Converters = map[string]string{"fields.data.array.*": "int", "fields.data.object.weapon": "string"}
+	c, err := api.NewContext(cfg)
+	if err != nil {
+		panic("unable to create context")
+	}
+	c.SetMeta("master", "localhost")
+	return c
+}
+
+func missionHandlers() *internal.Configuration {
+	cfg := testHandlers()
+	cfg.API.Handlers.Dump = false
+	cfg.API.Handlers.Messages = []string{"event", "start", "replay", "player"}
+	cfg.API.Schemas = "../configs/messages.yaml"
+	return cfg
+}
+
+func missionTests() {
+	c := newMissionContext()
+	m := make(map[string][]string)
+	m["filter"] = []string{"fields.data.array.0:eq:4"}
+	runTest(c, "arrayindex", m, missionHandlers(), true)
+	m["filter"] = []string{"fields.data.array.2:eq:3"}
+	runTest(c, "arrayindex2", m, missionHandlers(), true)
+	m["filter"] = []string{"fields.data.array.*:gt:5"}
+	runTest(c, "arraywildcard", m, missionHandlers(), true)
+	delete(m, "filter")
+	m["fields"] = []string{"id,fields.type.raw", "fields.data"}
+	runTest(c, "projection", m, missionHandlers(), true)
+	m["fields"] = []string{"id,ts,fields.simtime.value"}
+	m["sort"] = []string{"ts"}
+	runTest(c, "sortts", m, missionHandlers(), true)
+	m["sort"] = []string{"-ts"}
+	runTest(c, "sortdesc", m, missionHandlers(), true)
+	m["sort"] = []string{"-fields.simtime.value"}
+	runTest(c, "sortfield", m, missionHandlers(), true)
+	aggregateTest(c, "aggregate", map[string][]string{"group": {"fields.type.raw"}, "value": {"fields.simtime.value"}, "filter": {"fields.tag.raw:eq:abcd"}}, true)
+	aggregateTest(c, "aggregatebucket", map[string][]string{"group": {"fields.event.raw"}, "bucket": {"1m"}}, true)
+	aggregateTest(c, "badbucket", map[string][]string{"bucket": {"1us"}}, false)
+	aggregateTest(c, "aggregatecsv", map[string][]string{"group": {"fields.type.raw"}, "format": {"csv"}}, false)
+	distinctTest(c, "distinct", map[string][]string{"path": {"fields.type.raw"}, "filter": {"fields.tag.raw:eq:abcd"}})
+	formatTest(c, "ndjson", map[string][]string{"format": {"ndjson"}, "fields": {"id,fields.type.raw"}, "limit": {"2"}})
+	trailerTest(c, "ndjsonpage", map[string][]string{"format": {"ndjson"}, "fields": {"id"}, "limit": {"2"}})
+	formatTest(c, "csv", map[string][]string{"format": {"csv"}, "fields": {"id,ts,fields.type.raw,fields.data.array"}})
+	cursorTest(c, "cursor", nil)
+	cursorTest(c, "cursorsort", map[string][]string{"sort": {"ts"}})
+	cursorTest(c, "cursorskip", map[string][]string{"skip": {"1"}})
+	followTest(c, "follow")
+	m = make(map[string][]string)
+	m["filter"] = []string{"ts:gt:abc", "fields.simtime.value:gt:x"}
+	m["fields"] = []string{"id"}
+	m["unknown"] = []string{"1"}
+	m["timeout"] = []string{"soon"}
+	runTest(c, "strict", m, missionHandlers(), false)
+	m["strict"] = []string{"false"}
+	m["limit"] = []string{"0"}
+	runTest(c, "lenient", m, missionHandlers(), true)
+	q, err := api.DecodeQuery(strings.NewReader(`{"filters": [{"field": "fields.simtime.value", "op": "gt", "value": 11}, {"field": "fields.tag.raw", "op": "eq", "value": "abcd"}], "start": 1538766004000, "fields": ["id", "fields.simtime"], "sort": "-ts", "limit": 5}`))
+	if err != nil {
+		panic("unable to decode query")
+	}
+	runTest(c, "query", q.Values(), missionHandlers(), true)
+	m = make(map[string][]string)
+	m["start"] = []string{"2018-10-05T15:00:04-04:00"}
+	m["end"] = []string{"2018-10-05T19:00:05"}
+	m["tz"] = []string{"UTC"}
+	m["startdate"] = []string{"now-2d"}
+	m["fields"] = []string{"id"}
+	runTest(c, "times", m, missionHandlers(), true)
+	m["start"] = []string{"yesterday"}
+	m["tz"] = []string{"Mars/Olympus"}
+	runTest(c, "badtimes", m, missionHandlers(), false)
+	m = make(map[string][]string)
+	m["startdate"] = []string{"2018-10-05"}
+	m["enddate"] = []string{"2018-10-05"}
+	m["fields"] = []string{"id"}
+	runTest(c, "days", m, missionHandlers(), true)
+	// the dates are days in the given zone (east of the directories)
+	z := newMissionContext()
+	z.Zone = time.UTC
+	m["tz"] = []string{"Asia/Tokyo"}
+	runTest(z, "dayszone", m, missionHandlers(), true)
+	batchTest(c)
+	m = make(map[string][]string)
+	m["active"] = []string{"false"}
+	m["order"] = []string{"desc"}
+	tagTest(c, "missiontags", m, missionHandlers())
+	sessionTest(c, "sessions", "")
+	sessionTest(c, "session", "abcd")
+	playerTest(c, "players", "")
+	playerTest(c, "player", "76561198000000001")
+	timelineTest(c, "timeline", "abcd")
+	m = make(map[string][]string)
+	m["fields"] = []string{"id", "fields.tag"}
+	recordTest(c, "record", "2018-10-05T19-00-00.1538766000000.0.0", m, true)
+	recordTest(c, "recordmissing", "2018-10-05T19-30-00.1538766060000.0.9", m, false)
+	h := missionHandlers()
+	h.API.Schemas = "schema.yaml"
+	m = make(map[string][]string)
+	m["files"] = []string{"2018-10-05T19-00-00.1538766002000"}
+	m["fields"] = []string{"id", "fields"}
+	runTest(c, "schema", m, h, true)
+	// older (record) versions use the legacy schema
+	legacy := map[string][]string{"startdate": {"2018-10-05"}, "enddate": {"2018-10-05"}, "files": {"2018-10-05T18-00-00"}, "fields": {"id", "vers", "fields"}}
+	runTest(c, "schemalegacy", legacy, h, true)
+	h.API.Schemas = "types.yaml"
+	runTest(c, "schematypes", m, h, true)
+	// times are compared in order
+	tf := missionHandlers()
+	tf.API.Schemas = "timefields.yaml"
+	times := map[string][]string{"filter": {"fields.simtime.value:gt:1970-01-01T00:00:11Z", "fields.simtime:le:70500"}, "sort": {"-fields.simtime"}, "fields": {"id", "fields.simtime"}}
+	runTest(newConfiguredContext(tf), "schematimes", times, tf, true)
+	messages.Register("replayinfo", "replay", messages.HandlerFunc(func(entries map[string]*internal.Entry) map[string]*internal.Entry {
+		for k, v := range entries {
+			v.Name = strings.Replace(k, "field", "info", 1)
+		}
+		return entries
+	}))
+	h = missionHandlers()
+	h.API.Handlers.Messages = []string{"replayinfo"}
+	m["files"] = []string{"2018-10-05T19-00-00.1538766001000"}
+	runTest(c, "registered", m, h, true)
+	messages.RegisterVersions("playerinfo", "player", "", "1.1.0", messages.HandlerFunc(func(entries map[string]*internal.Entry) map[string]*internal.Entry {
+		for k, v := range entries {
+			v.Name = strings.Replace(k, "field", "legacy", 1)
+		}
+		return entries
+	}))
+	h.API.Handlers.Messages = []string{"playerinfo"}
+	legacy["files"] = []string{"2018-10-05T1"}
+	legacy["filter"] = []string{"ts:le:1538766002000"}
+	runTest(c, "registeredversions", legacy, h, true)
+	m = make(map[string][]string)
+	m["shape"] = []string{"flat"}
+	m["filter"] = []string{"fields.simtime:gt:11", "fields.tag:eq:abcd"}
+	m["sort"] = []string{"-fields.simtime"}
+	m["fields"] = []string{"id", "fields.simtime", "fields.data", "fields.type"}
+	runTest(c, "flat", m, missionHandlers(), true)
+	m = map[string][]string{"shape": {"flat"}, "filter": {"fields.data.weapon:eq:rifle"}, "fields": {"id", "fields.data"}}
+	runTest(c, "flatnested", m, missionHandlers(), true)
+	m = map[string][]string{"shape": {"flat"}, "filter": {"fields.type:eq:hit"}, "sort": {"fields.type"}, "fields": {"id", "fields.type"}}
+	runTest(c, "flatuntyped", m, missionHandlers(), true)
+	formatTest(c, "flatcsv", map[string][]string{"format": {"csv"}, "fields": {"id,fields.simtime,fields.type"}, "filter": {"fields.tag:eq:abcd"}})
+}
+
+func batchTest(c *api.Context) {
+	batch, err := api.DecodeBatch(strings.NewReader(`[{"name": "late", "query": {"filters": [{"field": "fields.simtime.value", "op": "gt", "value": 11}], "fields": ["id", "fields.simtime"]}}, {"name": "counts", "type": "aggregate", "params": {"group": ["fields.type.raw"]}}, {"name": "tags", "type": "distinct", "params": {"path": ["fields.tag.raw"]}}, {"name": "invalid", "query": {"format": "csv"}}]`))
+	if err != nil {
+		panic("unable to decode batch")
+	}
+	var b bytes.Buffer
+	api.HandleBatch(c, batch, missionHandlers(), &b)
+	writeResult(b.Bytes(), "batch")
+	// files of a day are dropped when no later query reads it
+	batch, err = api.DecodeBatch(strings.NewReader(`[{"name": "old", "query": {"startdate": "2018-10-05", "enddate": "2018-10-05", "fields": ["id"], "limit": 1}}, {"name": "recent", "query": {"fields": ["id"], "limit": 1}}, {"name": "again", "query": {"fields": ["id"], "limit": 1}}]`))
+	if err != nil {
+		panic("unable to decode batch")
+	}
+	b.Reset()
+	api.HandleBatch(c, batch, missionHandlers(), &b)
+	writeResult(b.Bytes(), "batchdays")
 }